@@ -73,8 +73,8 @@ func Reverse(c byte) byte {
 }
 
 func VisitsBoard(root *Node, t Tracker) (s string) {
-	boardsize := t.Boardsize()
-	board := make([]float64, boardsize*boardsize)
+	width, height := t.Width(), t.Height()
+	board := make([]float64, t.Sqsize())
 	max := 0.0
 	for child := root.Child; child != nil; child = child.Sibling {
 		if child.Visits > max {
@@ -82,20 +82,24 @@ func VisitsBoard(root *Node, t Tracker) (s string) {
 		}
 	}
 	for child := root.Child; child != nil; child = child.Sibling {
+		// pass has no point on the board
+		if child.Vertex == -1 {
+			continue
+		}
 		board[child.Vertex] = child.Visits / max
 		if root.Color == Reverse(WHITE) {
 			board[child.Vertex] = -board[child.Vertex]
 		}
 	}
-	for row := 0; row < boardsize; row++ {
-		for col := 0; col < boardsize; col++ {
-			v := row*boardsize + col
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			v := row*width + col
 			s += fmt.Sprintf("%.3f", board[v])
-			if col != boardsize-1 {
+			if col != width-1 {
 				s += " "
 			}
 		}
-		if row != boardsize-1 {
+		if row != height-1 {
 			s += "\n"
 		}
 	}
@@ -103,20 +107,20 @@ func VisitsBoard(root *Node, t Tracker) (s string) {
 }
 
 func TerritoryBoard(territory []float64, samples float64, t Tracker) (s string) {
-	boardsize := t.Boardsize()
-	for row := 0; row < boardsize; row++ {
-		for col := 0; col < boardsize; col++ {
-			v := row*boardsize + col
+	width, height := t.Width(), t.Height()
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			v := row*width + col
 			r := territory[v] / samples
 			red := uint32(0)
 			green := uint32(r * 255)
 			blue := uint32((1 - r) * 255)
 			s += fmt.Sprintf("0x%02.x%02.x%02.x", red, green, blue)
-			if col != boardsize-1 {
+			if col != width-1 {
 				s += " "
 			}
 		}
-		if row != boardsize-1 {
+		if row != height-1 {
 			s += "\n"
 		}
 	}
@@ -126,21 +130,24 @@ func TerritoryBoard(territory []float64, samples float64, t Tracker) (s string)
 func StatsBoard(root *Node, t Tracker) (s string) {
 	board := make([]string, t.Sqsize())
 	for child := root.Child; child != nil; child = child.Sibling {
+		if child.Vertex == -1 {
+			continue
+		}
 		board[child.Vertex] = fmt.Sprintf("%.0f/%.0f", child.Wins, child.Visits)
 	}
-	for row := 0; row < t.Boardsize(); row++ {
-		for col := 0; col < t.Boardsize(); col++ {
-			v := row*t.Boardsize() + col
+	for row := 0; row < t.Height(); row++ {
+		for col := 0; col < t.Width(); col++ {
+			v := row*t.Width() + col
 			if board[v] == "" {
 				s += "\"\""
 			} else {
 				s += board[v]
 			}
-			if col != t.Boardsize()-1 {
+			if col != t.Width()-1 {
 				s += " "
 			}
 		}
-		if row != t.Boardsize()-1 {
+		if row != t.Height()-1 {
 			s += "\n"
 		}
 	}
@@ -150,6 +157,9 @@ func StatsBoard(root *Node, t Tracker) (s string) {
 func FormatScore(t Tracker) string {
 	bc, wc := t.Score(t.GetKomi())
 	ex := bc - wc
+	if ex == 0 {
+		return "0"
+	}
 	if ex > 0 {
 		return fmt.Sprintf("B+%.1f", ex)
 	}
@@ -157,10 +167,10 @@ func FormatScore(t Tracker) string {
 }
 
 func LegalBoard(t Tracker, label map[byte]string) (s string) {
-	boardsize := t.Boardsize()
-	for row := 0; row < boardsize; row++ {
-		for col := 0; col < boardsize; col++ {
-			v := row*boardsize + col
+	width, height := t.Width(), t.Height()
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			v := row*width + col
 			if t.Legal(BLACK, v) && t.Legal(WHITE, v) {
 				s += label[BOTH]
 			} else if t.Legal(BLACK, v) && !t.Legal(WHITE, v) {
@@ -170,11 +180,11 @@ func LegalBoard(t Tracker, label map[byte]string) (s string) {
 			} else if !t.Legal(BLACK, v) && !t.Legal(WHITE, v) {
 				s += label[EMPTY]
 			}
-			if col != boardsize-1 {
+			if col != width-1 {
 				s += " "
 			}
 		}
-		if row != boardsize-1 {
+		if row != height-1 {
 			s += "\n"
 		}
 	}
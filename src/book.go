@@ -0,0 +1,802 @@
+package main
+
+import (
+	"fmt"
+	"gob"
+	"io/ioutil"
+	"json"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// moves searched less than this share of a position's visits are not expanded
+const BOOK_MIN_SHARE = 0.01
+
+// xored into the hash of positions with white to move
+const WHITE_TO_MOVE = BookHash(0x9e3779b97f4a7c15)
+
+// book hashes are 64 bits, a few million positions would give many collisions with 32
+type BookHash uint64
+
+/*
+	Opening book: search statistics for positions, keyed by the Zobrist hash of the
+	board in its canonical orientation (the symmetry with the smallest hash), so
+	rotated and mirrored positions and transpositions share one entry
+	moves are stored in the canonical orientation
+*/
+type Book struct {
+	Game      string
+	Boardsize int
+	Komi      float64
+	// 0 in books saved with 32 bit hashes, which are rehashed when read
+	HashBits  int
+	Positions map[BookHash]*BookPosition
+	config    *Config
+}
+
+type BookPosition struct {
+	// a line of play from the empty board reaching the position, colors alternate from black
+	Moves []int
+	// symmetry taking the board reached by Moves to the canonical orientation
+	Symmetry int
+	Color    byte
+	// search results for the player to move
+	Wins, Visits float64
+	Searches     int
+	Children     []*BookMove
+}
+
+type BookMove struct {
+	Vertex       int
+	Wins, Visits float64
+	// set once the move has been searched enough to be expanded
+	// Next is the hash of the position after the move
+	Expand bool
+	Next   BookHash
+}
+
+type BookMoves []*BookMove
+
+func (m BookMoves) Len() int {
+	return len(m)
+}
+
+func (m BookMoves) Less(i, j int) bool {
+	return m[i].Visits > m[j].Visits
+}
+
+func (m BookMoves) Swap(i, j int) {
+	m[i], m[j] = m[j], m[i]
+}
+
+func NewBook(config *Config) *Book {
+	b := new(Book)
+	b.Game = GameName(config)
+	b.Komi = config.Komi
+	if b.Game != "go" {
+		// only go trackers have komi
+		b.Komi = 0
+	}
+	b.Boardsize = config.Size
+	if b.Game == "havannah" {
+		// the width of the board array
+		b.Boardsize = 2*config.Size - 1
+	}
+	b.HashBits = 64
+	b.Positions = make(map[BookHash]*BookPosition)
+	b.config = config
+	return b
+}
+
+// board size as given in SGF files and by -size
+func (b *Book) size() int {
+	if b.Game == "havannah" {
+		return (b.Boardsize + 1) / 2
+	}
+	return b.Boardsize
+}
+
+// board symmetries that preserve the rules of the game, as arguments to transform
+// Hex only has the 180 degree rotation, which keeps each player's edges
+// the hexagonal Havannah board is not a square, only the identity is used
+func (b *Book) symmetries() []int {
+	switch b.Game {
+	case "hex":
+		return []int{0, 3}
+	case "havannah":
+		return []int{0}
+	case "y":
+		// the mirror image along the diagonal keeps the triangle and its neighbors
+		return []int{0, 4}
+	}
+	return []int{0, 1, 2, 3, 4, 5, 6, 7}
+}
+
+// map vertex by symmetry s: bit 0 mirrors columns, bit 1 mirrors rows, bit 2 transposes
+func (b *Book) transform(s, vertex int) int {
+	if vertex == -1 {
+		return -1
+	}
+	row, col := vertex/b.Boardsize, vertex%b.Boardsize
+	if s&1 != 0 {
+		col = b.Boardsize - 1 - col
+	}
+	if s&2 != 0 {
+		row = b.Boardsize - 1 - row
+	}
+	if s&4 != 0 {
+		row, col = col, row
+	}
+	return row*b.Boardsize + col
+}
+
+// undo transform(s, vertex)
+func (b *Book) inverse(s, vertex int) int {
+	if vertex == -1 {
+		return -1
+	}
+	row, col := vertex/b.Boardsize, vertex%b.Boardsize
+	if s&4 != 0 {
+		row, col = col, row
+	}
+	if s&2 != 0 {
+		row = b.Boardsize - 1 - row
+	}
+	if s&1 != 0 {
+		col = b.Boardsize - 1 - col
+	}
+	return row*b.Boardsize + col
+}
+
+// canonical hash of the position with color to move
+// returns the hash and the symmetry taking the board to the canonical orientation
+func (b *Book) Hash(t Tracker, color byte) (BookHash, int) {
+	board := t.Board()
+	z := zobrist_keys(b.Boardsize, b.Boardsize)
+	var best BookHash
+	sym := -1
+	for _, s := range b.symmetries() {
+		hash := BookHash(0)
+		for i := range board {
+			// off-board cells of the Havannah array hash as nothing, as in Hash.Update
+			if board[i] <= WHITE {
+				hash ^= z.book[board[i]][b.transform(s, i)]
+			}
+		}
+		if color == WHITE {
+			hash ^= WHITE_TO_MOVE
+		}
+		if sym == -1 || hash < best {
+			best, sym = hash, s
+		}
+	}
+	return best, sym
+}
+
+// book entry for the position in t with color to move, nil if there is none
+// books are square, so there is never an entry for a rectangular board
+// also returns the symmetry taking t's board to the orientation of the entry's moves
+func (b *Book) Lookup(t Tracker, color byte) (*BookPosition, int) {
+	if t.Width() != b.Boardsize || t.Height() != b.Boardsize || t.GetKomi() != b.Komi {
+		return nil, 0
+	}
+	hash, sym := b.Hash(t, color)
+	return b.Positions[hash], sym
+}
+
+// book moves for color in t's orientation, most searched first
+// the position may have been reached by any line of play, in any orientation
+func (b *Book) Candidates(t Tracker, color byte) BookMoves {
+	pos, sym := b.Lookup(t, color)
+	if pos == nil {
+		return nil
+	}
+	moves := make(BookMoves, len(pos.Children))
+	for i, move := range pos.Children {
+		cp := *move
+		cp.Vertex = b.inverse(sym, move.Vertex)
+		moves[i] = &cp
+	}
+	sort.Sort(moves)
+	return moves
+}
+
+/*
+	Book move for color in t's orientation, chosen among the moves with at least
+	BookMinVisits visits and a backed up winrate of at least BookMinWinrate
+	the most searched one, or with BookRandom a random one weighted by visits
+	false if no move qualifies
+*/
+func (b *Book) Move(t Tracker, color byte) (int, bool) {
+	values := make(map[BookHash]float64)
+	var moves BookMoves
+	total := 0.0
+	for _, move := range b.Candidates(t, color) {
+		if move.Visits >= b.config.BookMinVisits && b.winrate(move, values) >= b.config.BookMinWinrate {
+			moves = append(moves, move)
+			total += move.Visits
+		}
+	}
+	if len(moves) == 0 {
+		return -1, false
+	}
+	if b.config.BookRandom {
+		r := rand.Float64() * total
+		for _, move := range moves {
+			if r -= move.Visits; r < 0 {
+				return move.Vertex, true
+			}
+		}
+	}
+	return moves[0].Vertex, true
+}
+
+// backed up winrate of a move returned by Candidates
+func (b *Book) Winrate(move *BookMove) float64 {
+	return b.winrate(move, make(map[BookHash]float64))
+}
+
+// find or add the move at vertex, in the canonical orientation
+func (pos *BookPosition) move(vertex int) *BookMove {
+	for _, move := range pos.Children {
+		if move.Vertex == vertex {
+			return move
+		}
+	}
+	move := &BookMove{Vertex: vertex}
+	pos.Children = append(pos.Children, move)
+	return move
+}
+
+// tracker for the position reached by moves, colors alternating from black
+func (b *Book) replay(moves []int) (Tracker, byte) {
+	t := NewTracker(b.config)
+	t.SetKomi(b.Komi)
+	color := BLACK
+	for _, vertex := range moves {
+		t.Play(color, vertex)
+		color = Reverse(color)
+	}
+	return t, color
+}
+
+// search the position reached by moves and add the results to the book
+func (b *Book) search(moves []int) *BookPosition {
+	t, color := b.replay(moves)
+	hash, sym := b.Hash(t, color)
+	pos := b.Positions[hash]
+	if pos == nil {
+		pos = &BookPosition{Moves: moves, Symmetry: sym, Color: color}
+		b.Positions[hash] = pos
+	}
+	pos.Searches++
+	if winner := t.Winner(); winner != EMPTY {
+		if winner == color {
+			pos.Wins++
+		}
+		pos.Visits++
+		return pos
+	}
+	root := NewRoot(color, t, b.config)
+	genmove(root, t)
+	pos.Wins += root.Wins
+	pos.Visits += root.Visits
+	// without tree search the children are never visited, and have nothing to add
+	for child := root.Child; child != nil; child = child.Sibling {
+		if child.Visits == 0 {
+			continue
+		}
+		move := pos.move(b.transform(sym, child.Vertex))
+		move.Wins += child.Wins
+		move.Visits += child.Visits
+	}
+	for _, move := range pos.Children {
+		if !move.Expand && move.Visits >= BOOK_MIN_SHARE*pos.Visits {
+			cp := t.Copy()
+			cp.Play(color, b.inverse(sym, move.Vertex))
+			move.Next, _ = b.Hash(cp, Reverse(color))
+			move.Expand = true
+		}
+	}
+	return pos
+}
+
+// value of a position for the player to move: the best winrate among its expanded moves
+func (b *Book) value(hash BookHash, values map[BookHash]float64) float64 {
+	if value, ok := values[hash]; ok {
+		return value
+	}
+	pos := b.Positions[hash]
+	// set before recursing in case the book has a cycle
+	values[hash] = book_winrate(pos.Wins, pos.Visits)
+	best := -1.0
+	for _, move := range pos.Children {
+		if move.Expand {
+			best = math.Fmax(best, b.winrate(move, values))
+		}
+	}
+	if best >= 0 {
+		values[hash] = best
+	}
+	return values[hash]
+}
+
+// winrate of a move, backed up from the position it leads to if that is in the book
+func (b *Book) winrate(move *BookMove, values map[BookHash]float64) float64 {
+	if _, ok := b.Positions[move.Next]; ok && move.Expand {
+		return 1 - b.value(move.Next, values)
+	}
+	return book_winrate(move.Wins, move.Visits)
+}
+
+// wins per visit, an even 0.5 when nothing is known, which keeps NaN out of comparisons
+func book_winrate(wins, visits float64) float64 {
+	if visits == 0 {
+		return 0.5
+	}
+	return wins / visits
+}
+
+/*
+	Find the unsearched position with the lowest cost, where every ply from the
+	root costs 1 and every move costs BookWeight times the winrate it loses
+	against the best move of its position
+	returns the line of play reaching it, false if there is nothing left to expand
+*/
+func (b *Book) leaf(root BookHash) ([]int, bool) {
+	values := make(map[BookHash]float64)
+	costs := make(map[BookHash]float64)
+	var best []int
+	found := false
+	best_cost := math.Inf(1)
+	var visit func(hash BookHash, cost float64)
+	visit = func(hash BookHash, cost float64) {
+		if c, ok := costs[hash]; ok && c <= cost {
+			return
+		}
+		costs[hash] = cost
+		pos := b.Positions[hash]
+		if pos.Searches == 0 {
+			// only known from imported games
+			if cost < best_cost {
+				best_cost, best, found = cost, pos.Moves, true
+			}
+			return
+		}
+		if len(pos.Moves) >= b.config.BookDepth {
+			return
+		}
+		value := b.value(hash, values)
+		for _, move := range pos.Children {
+			if !move.Expand {
+				continue
+			}
+			c := cost + 1 + b.config.BookWeight*(value-b.winrate(move, values))
+			if _, ok := b.Positions[move.Next]; ok {
+				visit(move.Next, c)
+			} else if c < best_cost {
+				best_cost, found = c, true
+				best = make([]int, len(pos.Moves)+1)
+				copy(best, pos.Moves)
+				best[len(pos.Moves)] = b.inverse(pos.Symmetry, move.Vertex)
+			}
+		}
+	}
+	visit(root, 0)
+	return best, found
+}
+
+func (b *Book) filename() string {
+	if b.config.Bfile != "" {
+		return b.config.Bfile
+	} else if b.config.Prefix != "" {
+		return b.config.Prefix + ".book.gob"
+	}
+	return "book.gob"
+}
+
+func (b *Book) Save() {
+	f, err := os.Create(b.filename())
+	if err != nil {
+		panic(err)
+	}
+	defer func() { f.Close() }()
+	e := gob.NewEncoder(f)
+	err = e.Encode(b)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func readBook(filename string, config *Config) *Book {
+	b := NewBook(config)
+	// books saved before HashBits leave it alone
+	b.HashBits = 0
+	want := NewBook(config)
+	f, err := os.Open(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer func() { f.Close() }()
+	d := gob.NewDecoder(f)
+	err = d.Decode(b)
+	if err != nil {
+		panic(err)
+	}
+	if b.Game != want.Game || b.Boardsize != want.Boardsize {
+		panic(fmt.Sprintf("%s is a %s book for size %d", filename, b.Game, b.size()))
+	}
+	if b.HashBits != want.HashBits {
+		log.Println(filename, "has 32 bit hashes, rehashing")
+		b.rehash()
+	}
+	return b
+}
+
+// key the positions of a book read with 32 bit hashes by their 64 bit hashes
+// the canonical orientation may change with the hash, so moves are turned to match
+func (b *Book) rehash() {
+	positions := make(map[BookHash]*BookPosition)
+	for _, pos := range b.Positions {
+		t, color := b.replay(pos.Moves)
+		hash, sym := b.Hash(t, color)
+		for _, move := range pos.Children {
+			vertex := b.inverse(pos.Symmetry, move.Vertex)
+			move.Vertex = b.transform(sym, vertex)
+			if move.Expand {
+				cp := t.Copy()
+				cp.Play(color, vertex)
+				move.Next, _ = b.Hash(cp, Reverse(color))
+			}
+		}
+		pos.Symmetry = sym
+		positions[hash] = pos
+	}
+	b.Positions = positions
+	b.HashBits = 64
+}
+
+func LoadBook(config *Config) {
+	if config.Bfile == "" {
+		config.book = NewBook(config)
+		return
+	}
+	// the book is created on the first run that adds to it
+	if _, err := os.Stat(config.Bfile); err != nil && (config.Book || config.BookImport != "" || config.BookMerge != "") {
+		config.book = NewBook(config)
+		return
+	}
+	config.book = readBook(config.Bfile, config)
+}
+
+/*
+	Grow the book by BookIterations searches, each of the most promising position
+	not yet searched, saving after every search so the book can be resumed with -bfile
+*/
+func BuildBook(config *Config) {
+	b := config.book
+	t := NewTracker(config)
+	t.SetKomi(b.Komi)
+	root, _ := b.Hash(t, BLACK)
+	for i := uint(0); i < config.BookIterations; i++ {
+		var moves []int
+		if _, ok := b.Positions[root]; ok {
+			var found bool
+			if moves, found = b.leaf(root); !found {
+				log.Println("no positions left to expand")
+				break
+			}
+		}
+		pos := b.search(moves)
+		if config.Verbose {
+			line := ""
+			t, color := b.replay(moves)
+			for j := range moves {
+				line += t.Vtoa(moves[j]) + " "
+			}
+			log.Printf("%d: %s(%s to move) %.2f over %.0f visits\n", i, line, Ctoa(color), book_winrate(pos.Wins, pos.Visits), pos.Visits)
+		}
+		b.Save()
+	}
+	log.Println(b.String())
+}
+
+func (b *Book) String() string {
+	t := NewTracker(b.config)
+	t.SetKomi(b.Komi)
+	s := fmt.Sprintf("%s size %d book, komi %.1f, %d positions\n", b.Game, b.size(), b.Komi, len(b.Positions))
+	values := make(map[BookHash]float64)
+	for _, move := range b.Candidates(t, BLACK) {
+		s += fmt.Sprintf("%s %5.2f %6.0f", t.Vtoa(move.Vertex), book_winrate(move.Wins, move.Visits), move.Visits)
+		if _, ok := b.Positions[move.Next]; ok && move.Expand {
+			s += fmt.Sprintf(" %5.2f", b.winrate(move, values))
+		}
+		s += "\n"
+	}
+	return s
+}
+
+// add the statistics of another book of the same game, size and komi
+func (b *Book) Merge(other *Book) {
+	if other.Game != b.Game || other.Boardsize != b.Boardsize || other.Komi != b.Komi {
+		panic(fmt.Sprintf("cannot merge a %s %d komi %.1f book into a %s %d komi %.1f book",
+			other.Game, other.Boardsize, other.Komi, b.Game, b.Boardsize, b.Komi))
+	}
+	for hash, pos := range other.Positions {
+		mine := b.Positions[hash]
+		if mine == nil {
+			b.Positions[hash] = pos
+			continue
+		}
+		mine.Wins += pos.Wins
+		mine.Visits += pos.Visits
+		mine.Searches += pos.Searches
+		for _, move := range pos.Children {
+			m := mine.move(move.Vertex)
+			m.Wins += move.Wins
+			m.Visits += move.Visits
+			if move.Expand {
+				m.Expand, m.Next = true, move.Next
+			}
+		}
+	}
+}
+
+// merge the books in filenames (comma-separated) into config.book
+func MergeBooks(filenames string, config *Config) {
+	for _, filename := range strings.Split(filenames, ",") {
+		config.book.Merge(readBook(filename, config))
+	}
+	log.Println(config.book.String())
+}
+
+/*
+	Add the games in the SGF files matching patterns (comma-separated globs) to the book
+	every position among the first BookDepth moves of a game, and the move played from it,
+	count as one visit, won if the player to move won the game
+*/
+func ImportBook(patterns string, config *Config) {
+	games := 0
+	for _, pattern := range strings.Split(patterns, ",") {
+		filenames, err := filepath.Glob(pattern)
+		if err != nil {
+			panic(err)
+		}
+		for _, filename := range filenames {
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				panic(err)
+			}
+			trees, err := ParseSGF(string(data))
+			if err != nil {
+				log.Println(filename+":", err)
+			}
+			for _, tree := range trees {
+				if err := config.book.importGame(tree.MainLine()); err != nil {
+					log.Println(filename+":", err)
+				} else {
+					games++
+				}
+			}
+		}
+	}
+	log.Printf("imported %d games\n", games)
+	log.Println(config.book.String())
+}
+
+func (b *Book) importGame(nodes []SGFNode) os.Error {
+	if len(nodes) == 0 {
+		return os.NewError("empty game")
+	}
+	root := nodes[0]
+	if gm, ok := root["GM"]; ok && gm[0] != strconv.Itoa(SGFGame(b.config)) {
+		return os.NewError("game GM[" + gm[0] + "] does not match the book")
+	}
+	width, height := 19, 19
+	if sz, ok := root["SZ"]; ok {
+		width, height, _ = SGFSize(sz[0])
+	}
+	if width != b.size() || height != b.size() {
+		return os.NewError("board size does not match the book")
+	}
+	if b.Game == "go" {
+		komi := 0.0
+		if km, ok := root["KM"]; ok {
+			komi, _ = strconv.Atof64(km[0])
+		}
+		if komi != b.Komi {
+			return os.NewError("komi does not match the book")
+		}
+	}
+	if _, ok := root["AB"]; ok {
+		return os.NewError("setup stones are not supported")
+	}
+	if _, ok := root["AW"]; ok {
+		return os.NewError("setup stones are not supported")
+	}
+	var winner byte
+	if re, ok := root["RE"]; ok && strings.HasPrefix(re[0], "B+") {
+		winner = BLACK
+	} else if ok && strings.HasPrefix(re[0], "W+") {
+		winner = WHITE
+	} else {
+		return os.NewError("game has no winner")
+	}
+	t := NewTracker(b.config)
+	t.SetKomi(b.Komi)
+	var line []int
+	color := BLACK
+	for _, node := range nodes[1:] {
+		if len(line) >= b.config.BookDepth {
+			break
+		}
+		value, ok := node[Ctoa(color)]
+		if !ok {
+			// book lines alternate from black, stop at the first move out of turn
+			if _, ok := node[Ctoa(Reverse(color))]; ok {
+				break
+			}
+			continue
+		}
+		vertex, err := SGFVertex(value[0], b.Boardsize, b.Boardsize)
+		if err != nil {
+			return err
+		}
+		if !t.Legal(color, vertex) {
+			return os.NewError("illegal move " + t.Vtoa(vertex))
+		}
+		hash, sym := b.Hash(t, color)
+		pos := b.Positions[hash]
+		if pos == nil {
+			moves := make([]int, len(line))
+			copy(moves, line)
+			pos = &BookPosition{Moves: moves, Symmetry: sym, Color: color}
+			b.Positions[hash] = pos
+		}
+		move := pos.move(b.transform(sym, vertex))
+		pos.Visits++
+		move.Visits++
+		if winner == color {
+			pos.Wins++
+			move.Wins++
+		}
+		t.Play(color, vertex)
+		line = append(line, vertex)
+		move.Next, _ = b.Hash(t, Reverse(color))
+		move.Expand = true
+		color = Reverse(color)
+	}
+	return nil
+}
+
+// JSON form of a book, moves are given in the orientation of each position's line
+type bookJSON struct {
+	Game      string
+	Boardsize int
+	Komi      float64
+	Positions positionsJSON
+}
+
+type positionJSON struct {
+	Hash         string
+	Line         []string
+	Color        string
+	Wins, Visits float64
+	Searches     int
+	// best winrate backed up through the book
+	Value float64
+	Moves []*moveJSON
+}
+
+type moveJSON struct {
+	Move         string
+	Wins, Visits float64
+	Winrate      float64
+	InBook       bool
+}
+
+type positionsJSON []*positionJSON
+
+func (p positionsJSON) Len() int {
+	return len(p)
+}
+
+func (p positionsJSON) Less(i, j int) bool {
+	if len(p[i].Line) != len(p[j].Line) {
+		return len(p[i].Line) < len(p[j].Line)
+	}
+	return p[i].Visits > p[j].Visits
+}
+
+func (p positionsJSON) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+func (b *Book) toJSON() *bookJSON {
+	t := NewTracker(b.config)
+	values := make(map[BookHash]float64)
+	bj := &bookJSON{Game: b.Game, Boardsize: b.Boardsize, Komi: b.Komi}
+	for hash, pos := range b.Positions {
+		pj := &positionJSON{Hash: fmt.Sprintf("%016x", uint64(hash)), Color: Ctoa(pos.Color),
+			Wins: pos.Wins, Visits: pos.Visits, Searches: pos.Searches, Value: b.value(hash, values)}
+		for _, vertex := range pos.Moves {
+			pj.Line = append(pj.Line, t.Vtoa(vertex))
+		}
+		for _, move := range pos.Children {
+			mj := &moveJSON{Move: t.Vtoa(b.inverse(pos.Symmetry, move.Vertex)), Wins: move.Wins, Visits: move.Visits}
+			if move.Visits > 0 {
+				mj.Winrate = b.winrate(move, values)
+			}
+			_, in_book := b.Positions[move.Next]
+			mj.InBook = move.Expand && in_book
+			pj.Moves = append(pj.Moves, mj)
+		}
+		bj.Positions = append(bj.Positions, pj)
+	}
+	sort.Sort(bj.Positions)
+	return bj
+}
+
+// SGF variations for the book moves of the position in t, each position is written once
+func (b *Book) sgf(t Tracker, color byte, values map[BookHash]float64, seen map[BookHash]bool) string {
+	hash, sym := b.Hash(t, color)
+	pos := b.Positions[hash]
+	if pos == nil || seen[hash] {
+		return ""
+	}
+	seen[hash] = true
+	moves := make(BookMoves, 0, len(pos.Children))
+	for _, move := range pos.Children {
+		if move.Expand {
+			moves = append(moves, move)
+		}
+	}
+	sort.Sort(moves)
+	var variations []string
+	for _, move := range moves {
+		vertex := b.inverse(sym, move.Vertex)
+		node := fmt.Sprintf(";%sC[%.0f visits, winrate %.3f]", SGFMove(color, vertex, b.Boardsize), move.Visits, b.winrate(move, values))
+		cp := t.Copy()
+		cp.Play(color, vertex)
+		variations = append(variations, node+b.sgf(cp, Reverse(color), values, seen))
+	}
+	if len(variations) == 1 {
+		return variations[0]
+	}
+	s := ""
+	for _, variation := range variations {
+		s += "(" + variation + ")"
+	}
+	return s
+}
+
+// write the book to filename, as an SGF game tree if it ends in .sgf and as JSON otherwise
+func DumpBook(b *Book, filename string) {
+	var data []byte
+	if strings.HasSuffix(filename, ".sgf") {
+		t := NewTracker(b.config)
+		t.SetKomi(b.Komi)
+		s := "(;FF[4]"
+		// Havannah and Y have no GM number, Load takes the game from config without one
+		if gm := SGFGame(b.config); gm != 0 {
+			s += fmt.Sprintf("GM[%d]", gm)
+		}
+		s += fmt.Sprintf("SZ[%d]KM[%.1f]C[%d positions]", b.size(), b.Komi, len(b.Positions))
+		s += b.sgf(t, BLACK, make(map[BookHash]float64), make(map[BookHash]bool)) + ")\n"
+		data = []byte(s)
+	} else {
+		var err os.Error
+		if data, err = json.MarshalIndent(b.toJSON(), "", "  "); err != nil {
+			panic(err)
+		}
+	}
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		panic(err)
+	}
+}
@@ -1,96 +1,300 @@
 package main
 
 import (
-	"github.com/etherealmachine/redis.go"
+	"fmt"
 	"json"
 	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
 )
 
-func InitCluster(shutdown chan bool) {
+// published on the results channel when a job finishes
+type Result struct {
+	JobId      string
+	MsgType    string
+	Moves      []int
+	Best       int
+	BestVertex string
+	WinRate    float64
+	Winner     string
+	Seconds    float64
+	Error      string
+	Stats      *RootStats
+}
+
+// published on the workers channel when a worker starts, every heartbeat, and when it stops
+type Heartbeat struct {
+	Id        string
+	State     string
+	Capacity  int
+	Running   int
+	Completed int
+	Failed    int
+	Uptime    float64
+}
+
+type Worker struct {
+	id        string
+	capacity  int
+	running   int
+	completed int
+	failed    int
+	started   int64
+	draining  bool
+	lock      sync.Mutex
+	sem       chan int
+	transport Transport
+	config    *Config
+}
+
+/*
+	Start a cluster worker
+	jobs are popped from the "jobs" queue, one per free slot, and results are published
+	on the "results" channel
+	the "commands" channel is broadcast to every worker, it carries "shutdown" or a job
+	that every worker should run
+	on shutdown the worker stops taking jobs and waits for the ones in flight to finish
+*/
+func InitCluster(config *Config, transport Transport, shutdown chan bool) {
 	log.Println("setting up cluster...")
-	subscriptions := make(chan string, 1)
-	subscriptions <- "commands"
-	commands := make(chan redis.Message)
-	var client redis.Client
-	client.Addr = "127.0.0.1:6379"
-	go func() {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Println("Error trying to subscribe to redis channel")
-				log.Println(err)
-				shutdown <- true
-			}
-		}()
-		client.Subscribe(subscriptions, nil, nil, nil, commands)
-	}()
-	configs := make(chan Config)
-	go listen(commands, configs, shutdown)
-	go process(configs)
+	commands, err := transport.Subscribe("commands")
+	if err != nil {
+		log.Println("Error trying to subscribe to commands channel")
+		log.Println(err)
+		shutdown <- true
+		return
+	}
+	w := new(Worker)
+	hostname, _ := os.Hostname()
+	w.id = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	w.capacity = config.Workers
+	w.started = time.Nanoseconds()
+	w.sem = make(chan int, w.capacity)
+	w.transport = transport
+	w.config = config
+	w.heartbeat("up")
+	go w.listen(commands, shutdown)
+	go w.work()
+	go w.beat()
 }
 
-func listen(commands chan redis.Message, configs chan Config, shutdown chan bool) {
+func (w *Worker) listen(commands <-chan []byte, shutdown chan bool) {
 	log.Println("cluster up, listening for commands...")
-	for {
-		command := <-commands
-		if string(command.Message) == "shutdown" {
+	for command := range commands {
+		if string(command) == "shutdown" {
+			w.drain()
 			shutdown <- true
 			return
-		} else {
-			var config Config
-			if err := json.Unmarshal(command.Message, &config); err != nil {
-				log.Println(err)
-			}
-			configs <- config
 		}
+		// wait for a slot on the side, so a shutdown behind a job isn't held up until a job finishes
+		go func(command []byte) {
+			w.sem <- 1
+			if w.isDraining() {
+				<-w.sem
+				return
+			}
+			w.dispatch(command)
+		}(command)
 	}
 }
 
-func process(configs chan Config) {
-	sem := make(chan int, 4)
-	for config := range configs {
-		switch config.MsgType {
-		case "eval":
-			go eval(config, sem)
-		case "play":
-			go play(config, sem)
-		}
+// stop taking jobs and wait for every slot to be free
+func (w *Worker) drain() {
+	w.lock.Lock()
+	w.draining = true
+	running := w.running
+	w.lock.Unlock()
+	log.Printf("draining %d jobs...\n", running)
+	w.heartbeat("draining")
+	for i := 0; i < w.capacity; i++ {
+		w.sem <- 1
 	}
+	w.heartbeat("down")
 }
 
-func eval(config Config, sem chan int) {
-	/*
-		sem <- 1
-		t := NewTracker(&config)
-		color := BLACK
-		for i := range config.Moves {
-			t.Play(color, config.Moves[i])
-			color = Reverse(color)
+func (w *Worker) isDraining() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.draining
+}
+
+// pop jobs from the queue whenever there is a free slot
+func (w *Worker) work() {
+	for {
+		w.sem <- 1
+		if w.isDraining() {
+			<-w.sem
+			return
 		}
-		root := NewRoot(color, t, &config)
-		genmove(root, t)
-		eval := &Eval{config.Moves, root.Best().Vertex, root.Best().Wins / root.Best().Visits}
-		bytes, err := json.Marshal(eval)
+		job, err := w.transport.Pop("jobs", 1e9)
 		if err != nil {
 			log.Println(err)
-		} else {
-			var client redis.Client
-			client.Addr = "127.0.0.1:6379"
-			err := client.Publish("evals", bytes)
-			if err != nil {
-				log.Println(err)
+			<-w.sem
+			time.Sleep(1e9)
+			continue
+		}
+		if job == nil {
+			<-w.sem
+			continue
+		}
+		if w.isDraining() {
+			// popped while shutting down, give it back to another worker
+			w.transport.Push("jobs", job)
+			<-w.sem
+			return
+		}
+		w.dispatch(job)
+	}
+}
+
+func (w *Worker) beat() {
+	for _ = range time.Tick(int64(w.config.Heartbeat) * 1e9) {
+		if w.isDraining() {
+			return
+		}
+		w.heartbeat("up")
+	}
+}
+
+func (w *Worker) heartbeat(state string) {
+	w.lock.Lock()
+	hb := &Heartbeat{w.id, state, w.capacity, w.running, w.completed, w.failed,
+		float64(time.Nanoseconds()-w.started) / 1e9}
+	w.lock.Unlock()
+	bytes, err := json.Marshal(hb)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := w.transport.Publish("workers", bytes); err != nil {
+		log.Println(err)
+	}
+}
+
+// start the job in msg, the caller must hold a slot in sem which is released when the job is done
+func (w *Worker) dispatch(msg []byte) {
+	var config Config
+	if err := json.Unmarshal(msg, &config); err != nil {
+		log.Println(err)
+		publish(w.transport, &Result{Error: err.String()})
+		<-w.sem
+		return
+	}
+	switch config.MsgType {
+	case "eval":
+		go w.run(config, eval)
+	case "play":
+		go w.run(config, play)
+	case "search":
+		go w.run(config, search)
+	default:
+		publish(w.transport, &Result{JobId: config.JobId, MsgType: config.MsgType,
+			Error: "unknown message type " + config.MsgType})
+		<-w.sem
+	}
+}
+
+// run job, timing it and turning any panic into an error result
+func (w *Worker) run(config Config, job func(config *Config, result *Result)) {
+	defer func() { <-w.sem }()
+	w.lock.Lock()
+	w.running++
+	w.lock.Unlock()
+	result := &Result{JobId: config.JobId, MsgType: config.MsgType, Best: -1}
+	start := time.Nanoseconds()
+	func() {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Println(config.JobId, err)
+				result.Error = fmt.Sprint(err)
 			}
+		}()
+		job(&config, result)
+	}()
+	result.Seconds = float64(time.Nanoseconds()-start) / 1e9
+	w.lock.Lock()
+	w.running--
+	if result.Error == "" {
+		w.completed++
+	} else {
+		w.failed++
+	}
+	w.lock.Unlock()
+	publish(w.transport, result)
+}
+
+func publish(transport Transport, result *Result) {
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := transport.Publish("results", bytes); err != nil {
+		log.Println(err)
+	}
+}
+
+// replay config.Moves, with colors from config.Colors or alternating from black if it is empty
+// returns the tracker and the color to move, config.Color if it is set
+func replay(config *Config) (Tracker, byte) {
+	t := NewTracker(config)
+	color := BLACK
+	for i := range config.Moves {
+		if config.Colors != "" {
+			color = Atoc(config.Colors[i : i+1])
 		}
-		<-sem
-	*/
+		t.Play(color, config.Moves[i])
+		color = Reverse(color)
+	}
+	if config.Color != "" {
+		color = Atoc(config.Color)
+	}
+	return t, color
+}
+
+// search the position reached by config.Moves and report the best move
+func eval(config *Config, result *Result) {
+	t, color := replay(config)
+	root := NewRoot(color, t, config)
+	genmove(root, t)
+	best := root.Best()
+	result.Moves = config.Moves
+	result.Best = best.Vertex
+	result.BestVertex = t.Vtoa(best.Vertex)
+	result.WinRate = win_rate(best)
+}
+
+// wins per visit of node, 0 if it was never visited, as json can't encode NaN
+func win_rate(node *Node) float64 {
+	if node.Visits == 0 {
+		return 0
+	}
+	return node.Wins / node.Visits
+}
+
+// search the position reached by config.Moves and report the statistics of the root's children
+func search(config *Config, result *Result) {
+	t, color := replay(config)
+	t.SetKomi(config.Komi)
+	root := NewRoot(color, t, config)
+	genmove(root, t)
+	best := root.Best()
+	result.Moves = config.Moves
+	result.Best = best.Vertex
+	result.BestVertex = t.Vtoa(best.Vertex)
+	result.WinRate = win_rate(best)
+	result.Stats = root.Stats()
 }
 
-func play(config Config, sem chan int) byte {
-	sem <- 1
-	t := NewTracker(&config)
+// play a game between Black_policy_weights and White_policy_weights and report the winner
+func play(config *Config, result *Result) {
+	t := NewTracker(config)
 	var vertex int
 	for move := 0; ; {
 		config.policy_weights = config.Black_policy_weights
-		br := NewRoot(BLACK, t, &config)
+		br := NewRoot(BLACK, t, config)
 		genmove(br, t)
 		vertex = br.Best().Vertex
 		t.Play(BLACK, vertex)
@@ -99,7 +303,7 @@ func play(config Config, sem chan int) byte {
 			break
 		}
 		config.policy_weights = config.White_policy_weights
-		wr := NewRoot(WHITE, t, &config)
+		wr := NewRoot(WHITE, t, config)
 		genmove(wr, t)
 		vertex = wr.Best().Vertex
 		t.Play(WHITE, vertex)
@@ -112,6 +316,314 @@ func play(config Config, sem chan int) byte {
 		log.Println(Ctoa(t.Winner()))
 		log.Println(t.String())
 	}
-	<-sem
-	return t.Winner()
+	result.Moves = t.Moves().Copy()
+	result.Winner = Ctoa(t.Winner())
+}
+
+/*
+	Coordinator: read a JSON array of jobs from filename, each a partial Config
+	applied on top of config, queue them for the cluster and print each result
+	as a line of JSON once it arrives, or an error result if it times out
+*/
+func Submit(transport Transport, filename string, config *Config) {
+	f, err := os.Open(filename)
+	if err != nil {
+		panic(err)
+	}
+	var raw []json.RawMessage
+	err = json.NewDecoder(f).Decode(&raw)
+	f.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	results, err := transport.Subscribe("results")
+	if err != nil {
+		panic(err)
+	}
+
+	pending := make(map[string]bool)
+	for i := range raw {
+		job := *config
+		job.MsgType = "eval"
+		job.JobId = fmt.Sprintf("%d-%d", time.Nanoseconds(), i)
+		if err := json.Unmarshal(raw[i], &job); err != nil {
+			panic(err)
+		}
+		bytes, err := json.Marshal(&job)
+		if err != nil {
+			panic(err)
+		}
+		if err := transport.Push("jobs", bytes); err != nil {
+			panic(err)
+		}
+		pending[job.JobId] = true
+	}
+
+	timeout := time.After(int64(config.JobTimeout) * 1e9)
+	for len(pending) > 0 {
+		select {
+		case message := <-results:
+			var result Result
+			if err := json.Unmarshal(message, &result); err != nil {
+				log.Println(err)
+				continue
+			}
+			if !pending[result.JobId] {
+				continue
+			}
+			pending[result.JobId] = false, false
+			fmt.Println(string(message))
+		case <-timeout:
+			for id := range pending {
+				bytes, _ := json.Marshal(&Result{JobId: id, Best: -1, Error: "timed out"})
+				fmt.Println(string(bytes))
+			}
+			return
+		}
+	}
+}
+
+/*
+	Queue matches as play jobs and wait for the cluster to report winners
+	jobs that time out are resubmitted up to JobRetries times, jobs that fail are
+	resubmitted as soon as the error comes back, as long as they have retries left
+	gives up early if no worker responds at all, leaving the rest to be played locally
+*/
+func (s *Swarm) playRemote(matches []*Match) {
+	pending := make(map[string]*Match)
+	jobs := make(map[string][]byte)
+	failures := make(map[string]int)
+	for i, m := range matches {
+		job := *s.config
+		job.MsgType = "play"
+		job.JobId = fmt.Sprintf("%d-%d-%d", s.Generation, time.Nanoseconds(), i)
+		job.Black_policy_weights = m.Black
+		job.White_policy_weights = m.White
+		bytes, err := json.Marshal(&job)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		pending[job.JobId] = m
+		jobs[job.JobId] = bytes
+	}
+	for attempt := 0; attempt <= s.config.JobRetries && len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			log.Printf("resubmitting %d lost games\n", len(pending))
+		}
+		for id := range pending {
+			if err := s.transport.Push("jobs", jobs[id]); err != nil {
+				log.Println("cannot queue games, evaluating locally:", err)
+				return
+			}
+		}
+		received := 0
+		timeout := time.After(int64(s.config.JobTimeout) * 1e9)
+	wait:
+		for len(pending) > 0 {
+			select {
+			case msg := <-s.results:
+				var result Result
+				if err := json.Unmarshal(msg, &result); err != nil {
+					log.Println(err)
+					continue
+				}
+				m, exists := pending[result.JobId]
+				if !exists {
+					continue
+				}
+				received++
+				if result.Error != "" {
+					log.Println("game", result.JobId, "failed:", result.Error)
+					failures[result.JobId]++
+					if failures[result.JobId] > s.config.JobRetries {
+						// left for the caller to play locally
+						pending[result.JobId] = nil, false
+					} else if err := s.transport.Push("jobs", jobs[result.JobId]); err != nil {
+						log.Println("cannot queue games, evaluating locally:", err)
+						return
+					}
+					continue
+				}
+				m.Winner = Atoc(result.Winner)
+				m.played = true
+				pending[result.JobId] = nil, false
+				log.Printf("game %d / %d\n", len(matches)-len(pending), len(matches))
+			case <-timeout:
+				break wait
+			}
+		}
+		if received == 0 {
+			log.Println("no workers responded, evaluating locally")
+			return
+		}
+	}
+}
+
+/*
+	Root-parallel search: the coordinator broadcasts the position to every worker,
+	searches it locally at the same time, and adds the workers' root statistics
+	to its own tree before choosing a move
+	results are only subscribed to during genmove, so results of other jobs don't pile
+	up while the engine waits for the opponent
+*/
+type Coordinator struct {
+	transport Transport
+	lock      sync.Mutex
+	workers   map[string]int64
+	config    *Config
+}
+
+func NewCoordinator(config *Config) *Coordinator {
+	c := new(Coordinator)
+	c.config = config
+	c.transport = NewTransport(config)
+	c.workers = make(map[string]int64)
+	heartbeats, err := c.transport.Subscribe("workers")
+	if err != nil {
+		panic(err)
+	}
+	go c.track(heartbeats)
+	return c
+}
+
+// remember when each worker was last heard from
+func (c *Coordinator) track(heartbeats <-chan []byte) {
+	for msg := range heartbeats {
+		hb := new(Heartbeat)
+		if err := json.Unmarshal(msg, hb); err != nil {
+			log.Println(err)
+			continue
+		}
+		c.lock.Lock()
+		if hb.State == "up" {
+			c.workers[hb.Id] = time.Nanoseconds()
+		} else {
+			c.workers[hb.Id] = 0, false
+		}
+		c.lock.Unlock()
+	}
+}
+
+// number of workers that have sent a heartbeat recently
+func (c *Coordinator) Live() (live int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, seen := range c.workers {
+		if time.Nanoseconds()-seen < 3*int64(c.config.Heartbeat)*1e9 {
+			live++
+		}
+	}
+	return
+}
+
+// search on every live worker and locally, merging the workers' results into root
+func (c *Coordinator) genmove(root *Node, t Tracker, colors string) {
+	live := c.Live()
+	id := fmt.Sprintf("search-%d", time.Nanoseconds())
+	var results <-chan []byte
+	if live > 0 {
+		var err os.Error
+		if results, err = c.transport.Subscribe("results"); err != nil {
+			log.Println("searching locally:", err)
+			live = 0
+		} else {
+			defer c.transport.Unsubscribe("results", results)
+		}
+	}
+	if live > 0 {
+		job := *c.config
+		job.MsgType = "search"
+		job.JobId = id
+		job.Moves = t.Moves().Copy()
+		job.Colors = colors
+		job.Color = Ctoa(Reverse(root.Color))
+		job.Komi = t.GetKomi()
+		bytes, err := json.Marshal(&job)
+		if err == nil {
+			err = c.transport.Publish("commands", bytes)
+		}
+		if err != nil {
+			log.Println("searching locally:", err)
+			live = 0
+		}
+	}
+	genmove(root, t)
+	var timeout <-chan int64
+	if c.config.Timelimit > 0 {
+		// workers started at about the same time, allow for latency
+		timeout = time.After(2e9)
+	} else {
+		timeout = time.After(int64(c.config.JobTimeout) * 1e9)
+	}
+	for merged := 0; merged < live; {
+		select {
+		case msg := <-results:
+			var result Result
+			if err := json.Unmarshal(msg, &result); err != nil || result.JobId != id {
+				continue
+			}
+			merged++
+			if result.Error != "" {
+				log.Println("worker search failed:", result.Error)
+			} else if result.Stats != nil {
+				root.merge(result.Stats)
+			}
+		case <-timeout:
+			log.Printf("merged %d of %d searches\n", merged, live)
+			return
+		}
+	}
+}
+
+type Heartbeats []*Heartbeat
+
+func (h Heartbeats) Len() int {
+	return len(h)
+}
+
+func (h Heartbeats) Less(i, j int) bool {
+	return h[i].Id < h[j].Id
+}
+
+func (h Heartbeats) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+// listen for two heartbeats and print the live workers
+func Status(transport Transport, config *Config) {
+	messages, err := transport.Subscribe("workers")
+	if err != nil {
+		panic(err)
+	}
+	defer transport.Unsubscribe("workers", messages)
+	workers := make(map[string]*Heartbeat)
+	timeout := time.After(2 * int64(config.Heartbeat) * 1e9)
+	for listening := true; listening; {
+		select {
+		case msg := <-messages:
+			hb := new(Heartbeat)
+			if err := json.Unmarshal(msg, hb); err != nil {
+				log.Println(err)
+				continue
+			}
+			workers[hb.Id] = hb
+		case <-timeout:
+			listening = false
+		}
+	}
+	live := make(Heartbeats, 0, len(workers))
+	for _, hb := range workers {
+		if hb.State != "down" {
+			live = append(live, hb)
+		}
+	}
+	sort.Sort(live)
+	fmt.Printf("%-30s %-9s %5s %9s %6s %9s\n", "worker", "state", "load", "completed", "failed", "jobs/min")
+	for _, hb := range live {
+		fmt.Printf("%-30s %-9s %2d/%-2d %9d %6d %9.2f\n", hb.Id, hb.State, hb.Running, hb.Capacity,
+			hb.Completed, hb.Failed, 60*float64(hb.Completed)/hb.Uptime)
+	}
+	fmt.Printf("%d workers\n", len(live))
 }
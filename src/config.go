@@ -16,11 +16,18 @@ type Config struct {
 	PlayGame bool
 	SGF      string
 	Cluster  bool
+	Solve    bool
+	SolveVC  bool
+	Tsumego  string
+	Region   string
 
 	// Time limits
 	MaxPlayouts uint
 	Timelimit   int
 	Cutoff      float64
+	// Positions a tsumego or Hex solver search may visit
+	TsumegoNodes int
+	SolveNodes   int
 
 	// Log search stats
 	Stats bool
@@ -28,14 +35,21 @@ type Config struct {
 	Gfx bool
 
 	// Different games
-	Go      bool
-	Hex     bool
-	HexFast bool
+	Go         bool
+	Hex        bool
+	HexFast    bool
+	Gomoku     bool
+	GomokuRule string
+	Havannah   bool
+	Y          bool
+	Othello    bool
 
 	// Game-specific variables
 	Size     int
 	Komi     float64
 	Swapsafe bool
+	// rectangular boards for Go, Hex and Gomoku, 0 means Size
+	Width, Height int
 
 	// Learning
 	Train       bool
@@ -46,6 +60,30 @@ type Config struct {
 	Samples     uint
 	Propagate   uint
 	Combine     bool
+	EvalMode    string
+	Reference   string
+	Curve       uint
+	Distributed bool
+	JobRetries  int
+	RandSeed    int64
+	Summary     string
+
+	// Parameter tuning
+	Tune           bool
+	TuneParams     string
+	TuneIterations uint
+	TuneGames      uint
+
+	// Opening book building
+	BookIterations uint
+	BookDepth      int
+	BookWeight     float64
+	BookDump       string
+	BookImport     string
+	BookMerge      string
+	BookMinVisits  float64
+	BookMinWinrate float64
+	BookRandom     bool
 
 	// Load/save different modules
 	Prefix string
@@ -53,6 +91,11 @@ type Config struct {
 	Efile  string
 	Pfile  string
 	Sfile  string
+	Tfile  string
+
+	// Convert pattern weights between swarm and pattern files
+	ExportPatterns string
+	ImportPatterns string
 
 	// Tree exploration/expansion
 	TreeSearch                  bool
@@ -68,6 +111,8 @@ type Config struct {
 	PlayoutSuggest              bool
 	PlayoutSuggestUniform       bool
 	PlayoutSuggestUniformTenuki bool
+	PlayoutBridges              bool
+	Inferior                    bool
 
 	// Logging
 	Verbose      bool
@@ -77,11 +122,32 @@ type Config struct {
 	Lfile        string
 
 	// Used by cluster to store game history
-	Moves []int
+	// Colors holds the color of each move, moves alternate from black if it is empty
+	// Color is the color to move after Moves
+	Moves  []int
+	Colors string
+	Color  string
 
 	// Used by cluster to select message type
 	MsgType string
 
+	// Used by cluster to match results to jobs
+	JobId string
+
+	// Cluster coordinator
+	Submit     string
+	JobTimeout int
+
+	// Cluster transport
+	Transport   string
+	ClusterAddr string
+	Broker      bool
+
+	// Cluster workers
+	Workers   int
+	Heartbeat int
+	Status    bool
+
 	// Used by cluster
 	Black_policy_weights *Particle
 	White_policy_weights *Particle
@@ -90,7 +156,7 @@ type Config struct {
 	cfile string
 
 	// private fields, set by Bfile, Pfile and Efile
-	book           *Node
+	book           *Book
 	policy_weights *Particle
 
 	// log files
@@ -103,10 +169,24 @@ func NewConfig() *Config {
 	flag.BoolVar(&config.Help, "h", false, "Print this usage message")
 	flag.BoolVar(&config.Gtp, "gtp", false, "Listen on stdin for GTP commands")
 	flag.StringVar(&config.SGF, "sgf", "", "Load sgf file and generate move")
-	flag.BoolVar(&config.Book, "book", false, "Make opening book")
+	flag.BoolVar(&config.Book, "book", false, "Make or grow opening book (saved to bfile if given)")
 	flag.BoolVar(&config.Genmove, "genmove", false, "Generate one move and quit")
 	flag.BoolVar(&config.PlayGame, "playgame", false, "Self-play one game")
+	flag.BoolVar(&config.Solve, "solve", false, "(Hex) Solve the empty board, or the sgf position, and print the winner and a winning move")
+	flag.BoolVar(&config.SolveVC, "solve_vc", false, "(Solve) End and prune the search with virtual connections")
+	flag.IntVar(&config.SolveNodes, "solve_nodes", 1000000, "(Solve) Positions to search before giving up")
+	flag.StringVar(&config.Tsumego, "tsumego", "", "(Go) Decide whether the chain at this vertex of the sgf position lives, and print the key move")
+	flag.StringVar(&config.Region, "region", "", "(Tsumego) Corners of the region to search, like A1:E5 (default: the stones and one line around them)")
+	flag.IntVar(&config.TsumegoNodes, "tsumego_nodes", 1000000, "(Tsumego) Positions to search before giving up")
 	flag.BoolVar(&config.Cluster, "cluster", false, "Start cluster")
+	flag.StringVar(&config.Submit, "submit", "", "Submit a JSON array of jobs to the cluster and print the results")
+	flag.IntVar(&config.JobTimeout, "job_timeout", 600, "Seconds to wait for cluster results")
+	flag.StringVar(&config.Transport, "transport", "redis", "Cluster transport: redis, tcp or memory")
+	flag.StringVar(&config.ClusterAddr, "cluster_addr", "", "Address of redis server or tcp broker")
+	flag.BoolVar(&config.Broker, "broker", false, "Run a tcp broker for the cluster")
+	flag.IntVar(&config.Workers, "workers", 4, "Jobs a cluster worker runs at once")
+	flag.IntVar(&config.Heartbeat, "heartbeat", 10, "Seconds between cluster worker heartbeats")
+	flag.BoolVar(&config.Status, "status", false, "List live cluster workers")
 
 	flag.UintVar(&config.MaxPlayouts, "p", 10000, "Max number of playouts")
 	flag.IntVar(&config.Timelimit, "t", -1, "Max number of seconds")
@@ -118,8 +198,15 @@ func NewConfig() *Config {
 	flag.BoolVar(&config.Go, "go", false, "Play Go")
 	flag.BoolVar(&config.Hex, "hex", false, "Play Hex")
 	flag.BoolVar(&config.HexFast, "hexfast", false, "Play Hex using fast tracker")
+	flag.BoolVar(&config.Gomoku, "gomoku", false, "Play Gomoku")
+	flag.StringVar(&config.GomokuRule, "gomoku_rule", "freestyle", "Gomoku rule: freestyle, standard or renju")
+	flag.BoolVar(&config.Havannah, "havannah", false, "Play Havannah (size is the length of a side)")
+	flag.BoolVar(&config.Y, "y", false, "Play the Game of Y (size is the length of a side)")
+	flag.BoolVar(&config.Othello, "othello", false, "Play Othello (even sizes from 4 to 8)")
 
 	flag.IntVar(&config.Size, "size", 9, "Boardsize")
+	flag.IntVar(&config.Width, "width", 0, "Board width for rectangular Go, Hex and Gomoku boards (default: size)")
+	flag.IntVar(&config.Height, "height", 0, "Board height for rectangular Go, Hex and Gomoku boards (default: size)")
 	flag.Float64Var(&config.Komi, "komi", 6.5, "Komi")
 	flag.BoolVar(&config.Swapsafe, "swapsafe", false, "When playing hex, black will choose a swap-safe opening move")
 
@@ -131,11 +218,36 @@ func NewConfig() *Config {
 	flag.UintVar(&config.Samples, "samples", 7, "(Training) Evaluations per generation")
 	flag.UintVar(&config.Propagate, "prop", 2, "(Training) Propagate prop best from last generation")
 	flag.BoolVar(&config.Combine, "combine", false, "(Training) Use combination of all particles to form best")
+	flag.StringVar(&config.EvalMode, "eval", "random", "(Training) Fitness evaluation: random, reference or roundrobin")
+	flag.StringVar(&config.Reference, "ref", "", "(Training) Load reference particle from swarm file (default: no weights)")
+	flag.BoolVar(&config.Distributed, "distributed", false, "Use cluster workers for training games and GTP genmove")
+	flag.IntVar(&config.JobRetries, "job_retries", 2, "Times to resubmit cluster jobs that time out")
+	flag.Int64Var(&config.RandSeed, "rseed", 0, "(Training) Random seed (default: time)")
+	flag.StringVar(&config.Summary, "summary", "", "(Training) Print summary of the checkpoints in directory")
+	flag.UintVar(&config.Curve, "curve", 0, "(Training) Games against the reference per generation for the strength curve")
+
+	flag.BoolVar(&config.Tune, "tune", false, "(Tuning) Tune search parameters with SPSA")
+	flag.StringVar(&config.TuneParams, "tune_params", "c,k,e", "(Tuning) Comma-separated parameters to tune (c, k, e)")
+	flag.UintVar(&config.TuneIterations, "tune_iters", 100, "(Tuning) Iterations to tune for")
+	flag.UintVar(&config.TuneGames, "tune_games", 2, "(Tuning) Games per iteration")
+
+	flag.UintVar(&config.BookIterations, "book_iters", 100, "(Book) Positions to search")
+	flag.IntVar(&config.BookDepth, "book_depth", 10, "(Book) Max depth of book lines")
+	flag.Float64Var(&config.BookWeight, "book_weight", 10, "(Book) Plies one unit of winrate loss is worth when choosing positions to expand")
+	flag.StringVar(&config.BookDump, "book_dump", "", "(Book) Write book to file as JSON, or SGF if the name ends in .sgf")
+	flag.StringVar(&config.BookImport, "book_import", "", "(Book) Add games from SGF files to book (comma-separated globs)")
+	flag.StringVar(&config.BookMerge, "book_merge", "", "(Book) Add statistics of book files to book (comma-separated)")
+	flag.Float64Var(&config.BookMinVisits, "book_min_visits", 100, "(Book) Visits a book move needs to be played")
+	flag.Float64Var(&config.BookMinWinrate, "book_min_winrate", 0, "(Book) Winrate a book move needs to be played")
+	flag.BoolVar(&config.BookRandom, "book_random", false, "(Book) Choose book moves at random, weighted by visits")
 
 	flag.StringVar(&config.Prefix, "prefix", "", "Prefix to use when saving file")
 	flag.StringVar(&config.Sfile, "sfile", "", "Load swarm from file")
+	flag.StringVar(&config.Tfile, "tfile", "", "Load tuner state from file")
 	flag.StringVar(&config.Efile, "efile", "", "Load evaluator from file")
-	flag.StringVar(&config.Pfile, "pfile", "", "Load policy weights from file")
+	flag.StringVar(&config.Pfile, "pfile", "", "Load policy weights from swarm or pattern (.json) file")
+	flag.StringVar(&config.ExportPatterns, "export_patterns", "", "Write best weights of swarm to pattern file")
+	flag.StringVar(&config.ImportPatterns, "import_patterns", "", "Convert pattern file to swarm")
 	flag.StringVar(&config.Bfile, "bfile", "", "Load book from file")
 	flag.StringVar(&config.cfile, "cfile", "", "Load config from file")
 
@@ -152,6 +264,8 @@ func NewConfig() *Config {
 	flag.BoolVar(&config.PlayoutSuggest, "playout_suggest", false, "Use policy weights as suggested local response to move")
 	flag.BoolVar(&config.PlayoutSuggestUniform, "playout_suggest_uniform", false, "Use uniform random local response")
 	flag.BoolVar(&config.PlayoutSuggestUniformTenuki, "playout_suggest_uniform_tenuki", false, "Include probability of tenuki in local response")
+	flag.BoolVar(&config.PlayoutBridges, "playout_bridges", false, "(Hex) Answer intrusions into bridges and edge templates in playouts")
+	flag.BoolVar(&config.Inferior, "inferior", false, "(Hex) Leave dead, captured and dominated cells out of the search and fill them in playouts")
 
 	flag.BoolVar(&config.Verbose, "v", false, "Verbose logging")
 	flag.BoolVar(&config.VeryVerbose, "vv", false, "Very verbose logging")
@@ -165,20 +279,42 @@ func NewConfig() *Config {
 		config.Load()
 	}
 
-	LoadBook(config)
 	if config.Pfile != "" {
-		config.policy_weights = LoadBest(config.Pfile, config)
+		config.policy_weights = LoadPolicy(config.Pfile, config)
 	}
 
-	if !(config.Go || config.Hex) {
+	if config.Gomoku || config.Havannah || config.Y || config.Othello {
+		config.Go = false
+		config.Hex = false
+	}
+	if !(config.Go || config.Hex || config.Gomoku || config.Havannah || config.Y || config.Othello) {
 		config.Go = true
 	}
+	// the untouched default size of 9 is odd, any other bad size is rejected as boardsize does
+	if config.Othello && !othello_size(config.Size) {
+		size_set := false
+		flag.Visit(func(f *flag.Flag) { size_set = size_set || f.Name == "size" })
+		if size_set || config.Size != 9 {
+			panic("unacceptable size: Othello is played on even boards from 4 to 8")
+		}
+		config.Size = 8
+	}
 	if config.Go {
 		config.Hex = false
 	}
 	if config.Hex {
 		config.Go = false
 	}
+	if width, height := config.Dimensions(); width != height {
+		if !RectangularGame(config) {
+			panic(GameName(config) + " is only played on square boards")
+		}
+		if config.Book || config.BookImport != "" || config.BookMerge != "" || config.BookDump != "" {
+			panic("books need a square board")
+		}
+	}
+
+	LoadBook(config)
 
 	var f *os.File
 	var err os.Error
@@ -203,6 +339,18 @@ func NewConfig() *Config {
 	return config
 }
 
+// width and height of the board, -width and -height default to -size
+func (config *Config) Dimensions() (int, int) {
+	width, height := config.Width, config.Height
+	if width == 0 {
+		width = config.Size
+	}
+	if height == 0 {
+		height = config.Size
+	}
+	return width, height
+}
+
 func (config *Config) Load() {
 	f, err := os.Open(config.cfile)
 	if err != nil {
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"container/vector"
+	"fmt"
+	"log"
+	"rand"
+	"strconv"
+	"strings"
+)
+
+// the four lines through a vertex: horizontal, vertical and the two diagonals, as (row, col) steps
+var gomoku_dirs = [4][2]int{
+	[2]int{0, 1},
+	[2]int{1, 0},
+	[2]int{1, 1},
+	[2]int{1, -1},
+}
+
+/*
+	Five in a row, with one of three rules:
+	freestyle: five or more in a row wins
+	standard: exactly five wins, overlines don't
+	renju: white wins with five or more, black only with exactly five and may not
+	play an overline, a double four or a double three
+	a full board with no five is a draw, the winner is then BOTH
+*/
+type GomokuTracker struct {
+	width  int
+	height int
+	sqsize int
+	board  []byte
+	played []byte
+	// empty vertices in no particular order, and the position of each vertex in empty
+	empty []int
+	index []int
+	// vertices where each color would make five, checked again before they are used
+	threats [3][]int
+	// renju forbidden points of the current position, 0 unknown, 1 allowed, 2 forbidden
+	forbiddenPoints []byte
+	winner          byte
+	moves           *vector.IntVector
+	rule            string
+	config          *Config
+}
+
+func NewGomokuTracker(config *Config) *GomokuTracker {
+	t := new(GomokuTracker)
+
+	t.width, t.height = config.Dimensions()
+	t.sqsize = t.width * t.height
+	t.board = make([]byte, t.sqsize)
+	t.played = make([]byte, t.sqsize)
+	t.empty = make([]int, t.sqsize)
+	t.index = make([]int, t.sqsize)
+	t.forbiddenPoints = make([]byte, t.sqsize)
+	for i := 0; i < t.sqsize; i++ {
+		t.empty[i] = i
+		t.index[i] = i
+	}
+
+	t.winner = EMPTY
+
+	t.moves = new(vector.IntVector)
+
+	t.rule = config.GomokuRule
+	t.config = config
+
+	return t
+}
+
+func (t *GomokuTracker) Copy() Tracker {
+	cp := new(GomokuTracker)
+
+	cp.width = t.width
+	cp.height = t.height
+	cp.sqsize = t.sqsize
+	cp.board = make([]byte, cp.sqsize)
+	copy(cp.board, t.board)
+	cp.played = make([]byte, cp.sqsize)
+	cp.empty = make([]int, len(t.empty))
+	copy(cp.empty, t.empty)
+	cp.index = make([]int, cp.sqsize)
+	copy(cp.index, t.index)
+	for color := range t.threats {
+		cp.threats[color] = make([]int, len(t.threats[color]))
+		copy(cp.threats[color], t.threats[color])
+	}
+	cp.forbiddenPoints = make([]byte, cp.sqsize)
+	copy(cp.forbiddenPoints, t.forbiddenPoints)
+
+	cp.winner = t.winner
+
+	cp.moves = new(vector.IntVector)
+	*cp.moves = t.moves.Copy()
+
+	cp.rule = t.rule
+	cp.config = t.config
+
+	return cp
+}
+
+// vertex k steps from vertex along line d, -1 if that is off the board
+func (t *GomokuTracker) step(vertex, d, k int) int {
+	row := vertex/t.width + k*gomoku_dirs[d][0]
+	col := vertex%t.width + k*gomoku_dirs[d][1]
+	if row < 0 || row >= t.height || col < 0 || col >= t.width {
+		return -1
+	}
+	return row*t.width + col
+}
+
+// length of the row of color through vertex along line d, counting vertex as color
+func (t *GomokuTracker) run(color byte, vertex, d int) int {
+	n := 1
+	for k := 1; t.step(vertex, d, k) != -1 && t.board[t.step(vertex, d, k)] == color; k++ {
+		n++
+	}
+	for k := -1; t.step(vertex, d, k) != -1 && t.board[t.step(vertex, d, k)] == color; k-- {
+		n++
+	}
+	return n
+}
+
+// whether a row of n stones wins for color
+func (t *GomokuTracker) five(color byte, n int) bool {
+	if t.rule == "freestyle" || (t.rule == "renju" && color == WHITE) {
+		return n >= 5
+	}
+	return n == 5
+}
+
+// whether color wins by playing vertex
+func (t *GomokuTracker) wins(color byte, vertex int) bool {
+	for d := range gomoku_dirs {
+		if t.five(color, t.run(color, vertex, d)) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	With a black stone on vertex, the number of fours it makes on line d: the empty
+	vertices where one more black stone makes exactly five through vertex
+	the two ends of a straight four are one four, but B.BBB.B completed in the
+	middle is two
+*/
+func (t *GomokuTracker) fours(vertex, d int) int {
+	n, last := 0, 0
+	for k := -4; k <= 4; k++ {
+		e := t.step(vertex, d, k)
+		if e == -1 || t.board[e] != EMPTY || t.run(BLACK, e, d) != 5 || !t.joins(BLACK, vertex, d, k) {
+			continue
+		}
+		if n == 0 || k-last != 5 {
+			n++
+		}
+		last = k
+	}
+	return n
+}
+
+// whether the vertices between vertex and k steps along line d are all color
+func (t *GomokuTracker) joins(color byte, vertex, d, k int) bool {
+	sign := 1
+	if k < 0 {
+		sign = -1
+	}
+	for j := sign; j != k; j += sign {
+		if t.board[t.step(vertex, d, j)] != color {
+			return false
+		}
+	}
+	return true
+}
+
+// with a black stone on vertex, whether one more black stone on line d makes a straight four,
+// four in a row that can be made five at either end, with a move that isn't forbidden itself
+// that move is checked with depth-1, at depth 0 it is taken to be allowed
+func (t *GomokuTracker) openThree(vertex, d, depth int) bool {
+	for k := -4; k <= 4; k++ {
+		e := t.step(vertex, d, k)
+		if e == -1 || t.board[e] != EMPTY {
+			continue
+		}
+		t.board[e] = BLACK
+		open := false
+		if t.run(BLACK, e, d) == 4 {
+			ends := 0
+			for _, sign := range []int{1, -1} {
+				end := e
+				for end != -1 && t.board[end] == BLACK {
+					end = t.step(end, d, sign)
+				}
+				if end != -1 && t.board[end] == EMPTY && t.run(BLACK, end, d) == 5 {
+					ends++
+				}
+			}
+			open = ends == 2
+		}
+		t.board[e] = EMPTY
+		if open && (depth == 0 || !t.forbiddenDepth(e, depth-1)) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Renju: black may not make an overline, two fours or two open threes with one move,
+	unless it makes five
+	whether the move making a three into a straight four is forbidden is checked one
+	level deep, as practical renju checkers do, and the answer is kept until the next move
+*/
+func (t *GomokuTracker) forbidden(vertex int) bool {
+	if t.forbiddenPoints[vertex] == 0 {
+		t.forbiddenPoints[vertex] = 1
+		if t.forbiddenDepth(vertex, 1) {
+			t.forbiddenPoints[vertex] = 2
+		}
+	}
+	return t.forbiddenPoints[vertex] == 2
+}
+
+func (t *GomokuTracker) forbiddenDepth(vertex, depth int) bool {
+	if t.wins(BLACK, vertex) {
+		return false
+	}
+	t.board[vertex] = BLACK
+	overline := false
+	fours, threes := 0, 0
+	for d := range gomoku_dirs {
+		if t.run(BLACK, vertex, d) > 5 {
+			overline = true
+		} else if n := t.fours(vertex, d); n > 0 {
+			fours += n
+		} else if t.openThree(vertex, d, depth) {
+			threes++
+		}
+	}
+	t.board[vertex] = EMPTY
+	return overline || fours >= 2 || threes >= 2
+}
+
+func (t *GomokuTracker) Play(color byte, vertex int) {
+	if vertex != -1 {
+		if t.board[vertex] != EMPTY {
+			log.Println(t.String())
+			log.Println(Ctoa(color), t.Vtoa(vertex))
+			panic("play on non-empty vertex")
+		}
+		won := t.wins(color, vertex)
+		t.board[vertex] = color
+		for i := range t.forbiddenPoints {
+			t.forbiddenPoints[i] = 0
+		}
+		if t.played[vertex] == EMPTY {
+			t.played[vertex] = color
+		}
+		last := t.empty[len(t.empty)-1]
+		t.empty[t.index[vertex]] = last
+		t.index[last] = t.index[vertex]
+		t.empty = t.empty[0 : len(t.empty)-1]
+		if won {
+			t.winner = color
+		} else if len(t.empty) == 0 {
+			t.winner = BOTH
+		} else {
+			t.addThreats(color, vertex)
+		}
+	}
+	t.moves.Push(vertex)
+}
+
+// remember the vertices near vertex where color now makes five
+func (t *GomokuTracker) addThreats(color byte, vertex int) {
+	for d := range gomoku_dirs {
+		for k := -4; k <= 4; k++ {
+			e := t.step(vertex, d, k)
+			if e == -1 || t.board[e] != EMPTY || !t.wins(color, e) {
+				continue
+			}
+			known := false
+			for _, threat := range t.threats[color] {
+				known = known || threat == e
+			}
+			if !known {
+				t.threats[color] = append(t.threats[color], e)
+			}
+		}
+	}
+}
+
+// a vertex where color makes five, -1 if there is none
+func (t *GomokuTracker) threat(color byte) int {
+	threats := t.threats[color]
+	for len(threats) > 0 {
+		e := threats[len(threats)-1]
+		if t.board[e] == EMPTY && t.wins(color, e) {
+			break
+		}
+		threats = threats[0 : len(threats)-1]
+	}
+	t.threats[color] = threats
+	if len(threats) == 0 {
+		return -1
+	}
+	return threats[len(threats)-1]
+}
+
+// whether color playing vertex makes a straight four, two vertices on one line that each make five
+func (t *GomokuTracker) straightFour(color byte, vertex int) bool {
+	t.board[vertex] = color
+	defer func() { t.board[vertex] = EMPTY }()
+	for d := range gomoku_dirs {
+		n := 0
+		for k := -4; k <= 4; k++ {
+			e := t.step(vertex, d, k)
+			if e != -1 && t.board[e] == EMPTY && t.joins(color, vertex, d, k) && t.wins(color, e) {
+				n++
+			}
+		}
+		if n >= 2 {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	A legal vertex on the lines through near where color makes a straight four, -1 if
+	there is none
+	near is the last stone of color to find its own open threes, or of the opponent to
+	find where to answer the opponent's
+*/
+func (t *GomokuTracker) fourThreat(color byte, near int) int {
+	if near == -1 {
+		return -1
+	}
+	for d := range gomoku_dirs {
+		for k := -4; k <= 4; k++ {
+			e := t.step(near, d, k)
+			if e != -1 && t.board[e] == EMPTY && t.straightFour(color, e) && t.Legal(color, e) {
+				return e
+			}
+		}
+	}
+	return -1
+}
+
+// the move played back moves ago, -1 for a pass or before the start of the game
+func (t *GomokuTracker) last(back int) int {
+	if i := t.moves.Len() - back; i >= 0 {
+		return t.moves.At(i)
+	}
+	return -1
+}
+
+// random legal move, half the time close to the last move, -1 if color has none
+func (t *GomokuTracker) random(color byte) int {
+	if last := t.moves.Len() - 1; last >= 0 && t.moves.At(last) != -1 && rand.Float64() < 0.5 {
+		row, col := t.moves.At(last)/t.width, t.moves.At(last)%t.width
+		for tries := 0; tries < 8; tries++ {
+			r, c := row+rand.Intn(5)-2, col+rand.Intn(5)-2
+			if r >= 0 && r < t.height && c >= 0 && c < t.width && t.Legal(color, r*t.width+c) {
+				return r*t.width + c
+			}
+		}
+	}
+	for tries := 0; tries < 10; tries++ {
+		if vertex := t.empty[rand.Intn(len(t.empty))]; t.Legal(color, vertex) {
+			return vertex
+		}
+	}
+	for _, vertex := range t.empty {
+		if t.Legal(color, vertex) {
+			return vertex
+		}
+	}
+	return -1
+}
+
+/*
+	Playout: make five if possible, else block the opponent's five, which also answers
+	an open four as well as can be done, else make a straight four from an open three,
+	else block the straight four of the opponent's open three, else play at random
+*/
+func (t *GomokuTracker) Playout(color byte) {
+	for t.winner == EMPTY {
+		vertex := t.threat(color)
+		if vertex == -1 {
+			vertex = t.threat(Reverse(color))
+			if vertex != -1 && !t.Legal(color, vertex) {
+				vertex = -1
+			}
+		}
+		if vertex == -1 {
+			vertex = t.fourThreat(color, t.last(2))
+		}
+		if vertex == -1 {
+			vertex = t.fourThreat(Reverse(color), t.last(1))
+			if vertex != -1 && !t.Legal(color, vertex) {
+				vertex = -1
+			}
+		}
+		if vertex == -1 {
+			vertex = t.random(color)
+		}
+		if vertex == -1 {
+			// only forbidden moves left for black
+			t.winner = BOTH
+			break
+		}
+		if t.config.VeryVerbose {
+			log.Println(Ctoa(color) + t.Vtoa(vertex))
+		}
+		t.Play(color, vertex)
+		if t.config.VeryVerbose {
+			log.Println(t.String())
+		}
+		if t.config.Verify {
+			t.Verify()
+		}
+		color = Reverse(color)
+	}
+	if t.config.VeryVerbose {
+		log.Println("FINAL: " + Ctoa(t.winner))
+	}
+}
+
+func (t *GomokuTracker) WasPlayed(color byte, vertex int) bool {
+	return t.played[vertex] == color
+}
+
+func (t *GomokuTracker) Legal(color byte, vertex int) bool {
+	if vertex == -1 || t.board[vertex] != EMPTY {
+		return false
+	}
+	return t.rule != "renju" || color != BLACK || !t.forbidden(vertex)
+}
+
+func (t *GomokuTracker) Score(Komi float64) (float64, float64) {
+	switch t.winner {
+	case BLACK:
+		return 1, 0
+	case WHITE:
+		return 0, 1
+	case BOTH:
+		return 0.5, 0.5
+	}
+	return 0, 0
+}
+
+func (t *GomokuTracker) Winner() byte {
+	return t.winner
+}
+
+func (t *GomokuTracker) SetKomi(Komi float64) {
+
+}
+
+func (t *GomokuTracker) GetKomi() float64 {
+	return 0
+}
+
+func (t *GomokuTracker) Width() int {
+	return t.width
+}
+
+func (t *GomokuTracker) Height() int {
+	return t.height
+}
+
+func (t *GomokuTracker) Sqsize() int {
+	return t.sqsize
+}
+
+func (t *GomokuTracker) Board() []byte {
+	return t.board
+}
+
+func (t *GomokuTracker) Territory(color byte) []float64 {
+	territory := make([]float64, t.sqsize)
+	for i := range t.board {
+		if t.board[i] == color {
+			territory[i] = 1
+		}
+	}
+	return territory
+}
+
+func (t *GomokuTracker) Verify() {
+	count := 0
+	for i := range t.board {
+		if t.board[i] == EMPTY {
+			count++
+			if t.index[i] >= len(t.empty) || t.empty[t.index[i]] != i {
+				panic("empty vertex " + t.Vtoa(i) + " missing from empty list")
+			}
+		}
+	}
+	if count != len(t.empty) {
+		panic("empty list has occupied vertices")
+	}
+}
+
+// the eight vertices around vertex, -1 where they are off the board
+func (t *GomokuTracker) Adj(vertex int) []int {
+	adj := make([]int, 8)
+	for d := range gomoku_dirs {
+		adj[2*d] = t.step(vertex, d, 1)
+		adj[2*d+1] = t.step(vertex, d, -1)
+	}
+	return adj
+}
+
+func (t *GomokuTracker) Moves() *vector.IntVector {
+	return t.moves
+}
+
+func (t *GomokuTracker) Vtoa(v int) string {
+	if v == -1 {
+		return "PASS"
+	}
+	alpha, num := v%t.width, v/t.width
+	num = t.height - num
+	alpha = alpha + 'A'
+	if alpha >= 'I' {
+		alpha++
+	}
+	return fmt.Sprintf("%s%d", string(alpha), num)
+}
+
+func (t *GomokuTracker) Atov(s string) int {
+	if s == "PASS" || s == "pass" {
+		return -1
+	}
+	// pull apart into alpha and int pair
+	col := byte(strings.ToUpper(s)[0])
+	row, err := strconv.Atoi(s[1:len(s)])
+	row = t.height - row
+	if col >= 'I' {
+		col--
+	}
+	if err != nil {
+		panic("Failed to convert string to vertex")
+	}
+	return row*t.width + int(col-'A')
+}
+
+func (t *GomokuTracker) String() (s string) {
+	s += "   "
+	for col := 0; col < t.width; col++ {
+		alpha := col + 'A'
+		if alpha >= 'I' {
+			alpha++
+		}
+		s += string(alpha) + " "
+	}
+	for row := 0; row < t.height; row++ {
+		s += fmt.Sprintf("\n%2d ", t.height-row)
+		for col := 0; col < t.width; col++ {
+			s += Ctoa(t.board[row*t.width+col]) + " "
+		}
+		s += fmt.Sprintf("%d", t.height-row)
+	}
+	return
+}
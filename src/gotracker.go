@@ -13,14 +13,16 @@ import (
 // Tracks a game of Go
 // parent and rank comprise a union-find dataset to track chains
 // vertices in the same set are part of the same chain.
-// liberties returns the number of liberties for the chain
+// liberties holds a bitset of words uint64s for each vertex, the liberties of the chain
 // it is only correct for the root of the set
 type GoTracker struct {
-	boardsize int
+	width     int
+	height    int
 	sqsize    int
 	parent    []int
 	rank      []int
-	liberties [][2]uint64
+	liberties []uint64
+	words     int
 	board     []byte
 	weights   *WeightTree
 	atari     []map[int]int
@@ -29,14 +31,18 @@ type GoTracker struct {
 	koColor   byte
 	played    []byte
 	adj       [][]int
-	mask      [][4]uint64
 	neighbors [][][]int
+	zobrist   *Zobrist
 	passes    int
 	winner    byte
 	superko   bool
 	moves     *vector.IntVector
 	history   *vector.Vector
 	config    *Config
+	// scratch space for playoutMove and selfAtari, so playouts don't allocate every move
+	scratchLibs    []uint64
+	skipped        []int
+	skippedWeights []float64
 }
 
 // parent must be initialized so each element is a pointer to itself
@@ -45,15 +51,15 @@ type GoTracker struct {
 func NewGoTracker(config *Config) (t *GoTracker) {
 	t = new(GoTracker)
 
-	t.boardsize = config.Size
-	t.adj = go_adj[config.Size]
-	t.mask = masks[config.Size]
-	t.neighbors = go_neighbors[config.Size]
-	t.sqsize = config.Size * config.Size
+	t.width, t.height = config.Dimensions()
+	t.adj, t.neighbors = go_geometry(t.width, t.height)
+	t.zobrist = zobrist_keys(t.width, t.height)
+	t.sqsize = t.width * t.height
+	t.words = (t.sqsize + 63) / 64
 
 	t.parent = make([]int, t.sqsize)
 	t.rank = make([]int, t.sqsize)
-	t.liberties = make([][2]uint64, t.sqsize)
+	t.liberties = make([]uint64, t.sqsize*t.words)
 	t.board = make([]byte, t.sqsize)
 	t.weights = NewWeightTree(t.sqsize)
 	t.atari = make([]map[int]int, 3)
@@ -69,8 +75,7 @@ func NewGoTracker(config *Config) (t *GoTracker) {
 		for j := 0; j < 4; j++ {
 			adj := t.adj[i][j]
 			if adj != -1 {
-				t.liberties[i][0] |= t.mask[adj][0]
-				t.liberties[i][1] |= t.mask[adj][1]
+				t.addLiberty(i, adj)
 			}
 		}
 	}
@@ -82,20 +87,23 @@ func NewGoTracker(config *Config) (t *GoTracker) {
 	t.moves = new(vector.IntVector)
 	t.history = new(vector.Vector)
 	t.config = config
+	t.scratchLibs = make([]uint64, t.words)
 	return
 }
 
 func (t *GoTracker) Copy() Tracker {
 	cp := new(GoTracker)
 
-	cp.boardsize = t.boardsize
+	cp.width = t.width
+	cp.height = t.height
 	cp.adj = t.adj
-	cp.mask = t.mask
 	cp.neighbors = t.neighbors
+	cp.zobrist = t.zobrist
 	cp.sqsize = t.sqsize
+	cp.words = t.words
 	cp.parent = make([]int, cp.sqsize)
 	cp.rank = make([]int, cp.sqsize)
-	cp.liberties = make([][2]uint64, cp.sqsize)
+	cp.liberties = make([]uint64, len(t.liberties))
 	cp.board = make([]byte, cp.sqsize)
 	cp.weights = t.weights.Copy()
 	cp.atari = make([]map[int]int, 3)
@@ -125,6 +133,7 @@ func (t *GoTracker) Copy() Tracker {
 	cp.history = new(vector.Vector)
 	*cp.history = t.history.Copy()
 	cp.config = t.config
+	cp.scratchLibs = make([]uint64, cp.words)
 
 	return cp
 }
@@ -142,7 +151,7 @@ func (t *GoTracker) Play(color byte, vertex int) {
 
 		if t.superko {
 			if t.history.Len() == 0 {
-				t.history.Push(*NewHash(t.boardsize))
+				t.history.Push(*NewHash(t.zobrist))
 			}
 			cp := t.Copy()
 			cp.(*GoTracker).superko = false
@@ -165,26 +174,21 @@ func (t *GoTracker) Play(color byte, vertex int) {
 				t.atari[color][adj] = 0, false
 				// or in liberties to friendly chains
 				new_root, old_root := union(root, adj, t.parent, t.rank)
-				t.liberties[new_root][0] |= t.liberties[old_root][0]
-				t.liberties[new_root][1] |= t.liberties[old_root][1]
+				t.mergeLiberties(new_root, old_root)
 				// xor out liberty from self
-				t.liberties[new_root][0] &= ^t.mask[adj][0]
-				t.liberties[new_root][1] &= ^t.mask[adj][1]
+				t.removeLiberty(new_root, adj)
 				root = new_root
 			} else if adj != -1 && t.board[adj] == EMPTY {
 				// xor out liberty from empty vertices
-				t.liberties[adj][0] &= ^t.mask[vertex][0]
-				t.liberties[adj][1] &= ^t.mask[vertex][1]
+				t.removeLiberty(adj, vertex)
 			} else if adj != -1 {
 				// xor out liberties from enemy chains
 				enemy := find(adj, t.parent)
-				t.liberties[enemy][0] &= ^t.mask[vertex][0]
-				t.liberties[enemy][1] &= ^t.mask[vertex][1]
+				t.removeLiberty(enemy, vertex)
 			}
 		}
 		// xor out liberty from self
-		t.liberties[root][0] &= ^t.mask[vertex][0]
-		t.liberties[root][1] &= ^t.mask[vertex][1]
+		t.removeLiberty(root, vertex)
 
 		// capture any adjacent enemies reduced to zero liberties
 		var captured *vector.IntVector
@@ -346,8 +350,7 @@ func (t *GoTracker) capture(vertex int) *vector.IntVector {
 		capture := captured.At(i)
 		t.parent[capture] = capture
 		t.rank[capture] = 1
-		t.liberties[capture][0] = 0
-		t.liberties[capture][1] = 0
+		t.clearLiberties(capture)
 		t.board[capture] = EMPTY
 		t.weights.Set(BLACK, capture, INIT_WEIGHT)
 		t.weights.Set(WHITE, capture, INIT_WEIGHT)
@@ -359,8 +362,7 @@ func (t *GoTracker) capture(vertex int) *vector.IntVector {
 			adj := t.adj[capture][j]
 			if adj != -1 {
 				root := find(adj, t.parent)
-				t.liberties[root][0] |= t.mask[capture][0]
-				t.liberties[root][1] |= t.mask[capture][1]
+				t.addLiberty(root, capture)
 			}
 		}
 	}
@@ -368,14 +370,12 @@ func (t *GoTracker) capture(vertex int) *vector.IntVector {
 }
 
 // playout simulated game, call Winner() to retrive winner based on final territory
+// players pass once every move left is pointless, so the game ends with eyes and seki intact
 func (t *GoTracker) Playout(color byte) {
 	move := 0
 	t.superko = false
 	for {
-		vertex := t.playHeuristicMove(color)
-		if vertex == -1 {
-			vertex = t.weights.Rand(color)
-		}
+		vertex := t.playoutMove(color)
 		if t.config.VeryVerbose {
 			log.Println(Ctoa(color) + t.Vtoa(vertex))
 		}
@@ -461,6 +461,88 @@ func (t *GoTracker) playHeuristicMove(color byte) int {
 	return -1
 }
 
+// a heuristic move, or a random one, that isn't pointless, -1 to pass if there is none
+func (t *GoTracker) playoutMove(color byte) int {
+	if vertex := t.playHeuristicMove(color); vertex != -1 && !t.pointless(color, vertex) {
+		return vertex
+	}
+	// pointless moves are taken out of the weights until a move is drawn, then put back
+	t.skipped, t.skippedWeights = t.skipped[0:0], t.skippedWeights[0:0]
+	vertex := t.weights.Rand(color)
+	for vertex != -1 && t.pointless(color, vertex) {
+		t.skipped = append(t.skipped, vertex)
+		t.skippedWeights = append(t.skippedWeights, t.weights.Get(color, vertex))
+		t.weights.Set(color, vertex, 0)
+		vertex = t.weights.Rand(color)
+	}
+	for i, v := range t.skipped {
+		t.weights.Set(color, v, t.skippedWeights[i])
+	}
+	return vertex
+}
+
+// moves a playout never makes: filling an eye of its own, or self-atari
+func (t *GoTracker) pointless(color byte, vertex int) bool {
+	return t.eye(color, vertex) || t.selfAtari(color, vertex)
+}
+
+// indices of the diagonals in a 3x3 neighborhood
+var go_diagonals = []int{0, 2, 6, 8}
+
+// whether the empty vertex is an eye of color: all its neighbors are color's, and the
+// opponent has no diagonal on the edge, and at most one elsewhere
+func (t *GoTracker) eye(color byte, vertex int) bool {
+	for _, adj := range t.adj[vertex] {
+		if adj != -1 && t.board[adj] != color {
+			return false
+		}
+	}
+	opponent, edge := 0, false
+	for _, i := range go_diagonals {
+		diagonal := t.neighbors[1][vertex][i]
+		if diagonal == -1 {
+			edge = true
+		} else if t.board[diagonal] == Reverse(color) {
+			opponent++
+		}
+	}
+	return opponent == 0 || (opponent == 1 && !edge)
+}
+
+// whether color at the empty vertex joins its stones into a chain with one liberty
+// without capturing, which is how seki is broken
+// a lone stone may still be put in atari, to kill by playing inside an eye space, but
+// the nakade and throw-ins of two or more stones are never played
+func (t *GoTracker) selfAtari(color byte, vertex int) bool {
+	libs := t.scratchLibs
+	for i := range libs {
+		libs[i] = 0
+	}
+	joins := false
+	for _, adj := range t.adj[vertex] {
+		switch {
+		case adj == -1:
+		case t.board[adj] == EMPTY:
+			libs[adj>>6] |= 1 << uint(adj&63)
+		case t.board[adj] == color:
+			joins = true
+			for i, u := range t.libset(find(adj, t.parent)) {
+				libs[i] |= u
+			}
+		case t.libs(find(adj, t.parent)) == 1:
+			return false
+		}
+	}
+	libs[vertex>>6] &^= 1 << uint(vertex&63)
+	count := 0
+	for _, u := range libs {
+		for ; u != 0; count++ {
+			u &= u - 1
+		}
+	}
+	return joins && count <= 1
+}
+
 func (t *GoTracker) WasPlayed(color byte, vertex int) bool {
 	if vertex == -1 {
 		return false
@@ -484,17 +566,53 @@ func (t *GoTracker) Legal(color byte, vertex int) bool {
 	return vertex == -1 || t.weights.Get(color, vertex) != 0
 }
 
+// area score, stones and the empty points only one color reaches
 func (t *GoTracker) Score(komi float64) (float64, float64) {
-	bc, wc := 0.0, 0.0
-	for i := 0; i < t.sqsize; i++ {
-		if t.board[i] == BLACK {
-			bc++
-		} else if t.board[i] == WHITE {
-			wc++
+	score := make([]float64, 3)
+	for _, owner := range t.area() {
+		if owner == BLACK || owner == WHITE {
+			score[owner]++
 		}
 	}
-	wc += komi
-	return bc, wc
+	return score[BLACK], score[WHITE] + komi
+}
+
+/*
+	Owner of each point under area scoring: the color of a stone, and of an empty region
+	bordered by one color only
+	a region bordered by both, like the shared liberties of a seki, is BOTH, an empty board EMPTY
+*/
+func (t *GoTracker) area() []byte {
+	owner := make([]byte, t.sqsize)
+	seen := make([]bool, t.sqsize)
+	for v := range t.board {
+		if t.board[v] != EMPTY {
+			owner[v] = t.board[v]
+			continue
+		}
+		if seen[v] {
+			continue
+		}
+		region, borders := []int{v}, EMPTY
+		seen[v] = true
+		for i := 0; i < len(region); i++ {
+			for _, adj := range t.adj[region[i]] {
+				if adj == -1 {
+					continue
+				}
+				if t.board[adj] != EMPTY {
+					borders |= t.board[adj]
+				} else if !seen[adj] {
+					seen[adj] = true
+					region = append(region, adj)
+				}
+			}
+		}
+		for _, r := range region {
+			owner[r] = borders
+		}
+	}
+	return owner
 }
 
 func (t *GoTracker) Winner() byte {
@@ -521,8 +639,12 @@ func (t *GoTracker) GetKomi() float64 {
 	return t.komi
 }
 
-func (t *GoTracker) Boardsize() int {
-	return t.boardsize
+func (t *GoTracker) Width() int {
+	return t.width
+}
+
+func (t *GoTracker) Height() int {
+	return t.height
 }
 
 func (t *GoTracker) Sqsize() int {
@@ -534,13 +656,13 @@ func (t *GoTracker) Board() []byte {
 }
 
 func (t *GoTracker) Adj(vertex int) []int {
-	return go_adj[t.boardsize][vertex]
+	return t.adj[vertex]
 }
 
 func (t *GoTracker) Territory(color byte) []float64 {
 	territory := make([]float64, t.sqsize)
-	for i := range t.board {
-		if t.board[i] == color {
+	for i, owner := range t.area() {
+		if owner == color {
 			territory[i] = 1
 		}
 	}
@@ -591,30 +713,44 @@ func (t *GoTracker) Verify() {
 			}
 		}
 	}
+	// every chain's liberty bitset holds exactly the empty vertices next to its stones
+	expected := make([]uint64, len(t.liberties))
+	for i := 0; i < t.sqsize; i++ {
+		if t.board[i] == EMPTY {
+			continue
+		}
+		root := find(i, t.parent)
+		for j := 0; j < 4; j++ {
+			adj := t.adj[i][j]
+			if adj != -1 && t.board[adj] == EMPTY {
+				expected[root*t.words+adj>>6] |= 1 << uint(adj&63)
+			}
+		}
+	}
+	for i := 0; i < t.sqsize; i++ {
+		if t.board[i] != EMPTY && find(i, t.parent) == i {
+			for w := 0; w < t.words; w++ {
+				if t.liberties[i*t.words+w] != expected[i*t.words+w] {
+					log.Println(t.libertyboard(i))
+					panic("wrong liberties for chain at " + t.Vtoa(i))
+				}
+			}
+		}
+	}
 }
 
 func (t *GoTracker) checkNoMoreLegal() {
 	if t.winner == EMPTY {
 		return
 	}
+	// every legal move left is pointless
 	for i := 0; i < t.sqsize; i++ {
 		if t.board[i] == EMPTY {
-			legal_black := t.weights.Get(BLACK, i) != 0
-			legal_white := t.weights.Get(WHITE, i) != 0
-			if legal_black || legal_white {
-				panic(t.Vtoa(i))
-			}
-			for j := 0; j < 4; j++ {
-				if t.adj[i][j] != -1 {
-					adj := find(t.adj[i][j], t.parent)
-					if t.libs(adj) == 1 {
-						panic(t.Vtoa(i))
-					}
+			for _, color := range []byte{BLACK, WHITE} {
+				if t.weights.Get(color, i) != 0 && !t.pointless(color, i) {
+					panic(t.Vtoa(i))
 				}
 			}
-			if (!legal_black && t.weights.Get(BLACK, i) != 0) || (!legal_white && t.weights.Get(WHITE, i) != 0) {
-				panic(t.Vtoa(i))
-			}
 		}
 	}
 }
@@ -627,8 +763,8 @@ func (t *GoTracker) Vtoa(v int) string {
 	if v == -1 {
 		return "PASS"
 	}
-	alpha, num := v%t.boardsize, v/t.boardsize
-	num = t.boardsize - num
+	alpha, num := v%t.width, v/t.width
+	num = t.height - num
 	alpha = alpha + 'A'
 	if alpha >= 'I' {
 		alpha++
@@ -643,51 +779,51 @@ func (t *GoTracker) Atov(s string) int {
 	// pull apart into alpha and int pair
 	col := byte(strings.ToUpper(s)[0])
 	row, err := strconv.Atoi(s[1:len(s)])
-	row = t.boardsize - row
+	row = t.height - row
 	if col >= 'I' {
 		col--
 	}
 	if err != nil {
 		panic("Failed to convert string to vertex")
 	}
-	return row*t.boardsize + int(col-'A')
+	return row*t.width + int(col-'A')
 }
 
 func (t *GoTracker) String() (s string) {
 	s += "  "
-	for col := 0; col < t.boardsize; col++ {
+	for col := 0; col < t.width; col++ {
 		alpha := col + 'A'
 		if alpha >= 'I' {
 			alpha++
 		}
 		s += string(alpha)
-		if col != t.boardsize-1 {
+		if col != t.width-1 {
 			s += " "
 		}
 	}
 	s += "\n"
-	for row := 0; row < t.boardsize; row++ {
-		s += fmt.Sprintf("%d ", t.boardsize-row)
-		for col := 0; col < t.boardsize; col++ {
-			v := row*t.boardsize + col
+	for row := 0; row < t.height; row++ {
+		s += fmt.Sprintf("%d ", t.height-row)
+		for col := 0; col < t.width; col++ {
+			v := row*t.width + col
 			s += Ctoa(t.board[v])
-			if col != t.boardsize-1 {
+			if col != t.width-1 {
 				s += " "
 			}
 		}
-		s += fmt.Sprintf(" %d", t.boardsize-row)
-		if row != t.boardsize-1 {
+		s += fmt.Sprintf(" %d", t.height-row)
+		if row != t.height-1 {
 			s += "\n"
 		}
 	}
 	s += "\n  "
-	for col := 0; col < t.boardsize; col++ {
+	for col := 0; col < t.width; col++ {
 		alpha := col + 'A'
 		if alpha >= 'I' {
 			alpha++
 		}
 		s += string(alpha)
-		if col != t.boardsize-1 {
+		if col != t.width-1 {
 			s += " "
 		}
 	}
@@ -697,17 +833,7 @@ func (t *GoTracker) String() (s string) {
 func (t *GoTracker) dead() []int {
 	dead := new(vector.IntVector)
 	cp := t.Copy().(*GoTracker)
-	color := BLACK
-	move := 0
-	for {
-		vertex := cp.weights.Rand(color)
-		cp.Play(color, vertex)
-		move++
-		if move > 3*t.sqsize || cp.Winner() != EMPTY {
-			break
-		}
-		color = Reverse(color)
-	}
+	cp.Playout(BLACK)
 	for i := 0; i < t.sqsize; i++ {
 		if t.board[i] != EMPTY && cp.board[i] != t.board[i] {
 			dead.Push(i)
@@ -733,146 +859,99 @@ func (t *GoTracker) get_weight(color byte, vertex int) float64 {
 	return weight
 }
 
-func (t *GoTracker) libs(vertex int) uint {
-	return bitcount(t.liberties[vertex][0], t.liberties[vertex][1])
+// liberty bitset of vertex
+func (t *GoTracker) libset(vertex int) []uint64 {
+	return t.liberties[vertex*t.words : (vertex+1)*t.words]
+}
+
+func (t *GoTracker) addLiberty(root, liberty int) {
+	t.liberties[root*t.words+liberty>>6] |= 1 << uint(liberty&63)
+}
+
+func (t *GoTracker) removeLiberty(root, liberty int) {
+	t.liberties[root*t.words+liberty>>6] &^= 1 << uint(liberty&63)
 }
 
-func bitcount(u uint64, v uint64) (c uint) {
-	// c accumulates the total bits set in v
-	for c = 0; u != 0; c++ {
-		u &= u - 1 // clear the least significant bit set
+func (t *GoTracker) hasLiberty(root, liberty int) bool {
+	return t.liberties[root*t.words+liberty>>6]&(1<<uint(liberty&63)) != 0
+}
+
+// or the liberties of src into dst
+func (t *GoTracker) mergeLiberties(dst, src int) {
+	d, s := t.libset(dst), t.libset(src)
+	for i := range d {
+		d[i] |= s[i]
 	}
-	for ; v != 0; c++ {
-		v &= v - 1 // clear the least significant bit set
+}
+
+func (t *GoTracker) clearLiberties(vertex int) {
+	libs := t.libset(vertex)
+	for i := range libs {
+		libs[i] = 0
 	}
-	return
+}
+
+func (t *GoTracker) libs(vertex int) uint {
+	c := uint(0)
+	for _, u := range t.libset(vertex) {
+		// clear the least significant bit set
+		for ; u != 0; c++ {
+			u &= u - 1
+		}
+	}
+	return c
 }
 
 // return the index of the first liberty
 func (t *GoTracker) lastliberty(root int) int {
-	v0, v1 := t.liberties[root][0], t.liberties[root][1]
-	for row := 0; row < t.boardsize; row++ {
-		for col := 0; col < t.boardsize; col++ {
-			vertex := row*t.boardsize + col
-			var v uint64
-			var bit uint64
-			if vertex < 64 {
-				bit = uint64(64 - vertex - 1)
-				v = v0
-			} else {
-				bit = uint64(64 - (vertex - 64) - 1)
-				v = v1
-			}
-			var mask uint64 = 1 << bit
-			if (mask & v) != 0 {
-				return vertex
+	for i, u := range t.libset(root) {
+		if u != 0 {
+			vertex := i * 64
+			for u&1 == 0 {
+				u >>= 1
+				vertex++
 			}
+			return vertex
 		}
 	}
 	return -1
 }
 
 func (t *GoTracker) libertyboard(root int) (s string) {
-	v0, v1 := t.liberties[root][0], t.liberties[root][1]
 	s += "  "
-	for col := 0; col < t.boardsize; col++ {
+	for col := 0; col < t.width; col++ {
 		alpha := col + 'A'
 		if alpha >= 'I' {
 			alpha = alpha + 1
 		}
 		s += string(alpha)
-		if col != t.boardsize-1 {
+		if col != t.width-1 {
 			s += " "
 		}
 	}
 	s += "\n"
-	for row := 0; row < t.boardsize; row++ {
-		s += fmt.Sprintf("%d ", t.boardsize-row)
-		for col := 0; col < t.boardsize; col++ {
-			vertex := row*t.boardsize + col
-			var v uint64
-			var bit uint64
-			if vertex < 64 {
-				bit = uint64(64 - vertex - 1)
-				v = v0
-			} else {
-				bit = uint64(64 - (vertex - 64) - 1)
-				v = v1
-			}
-			var mask uint64 = 1 << bit
-			if (mask & v) == 0 {
-				s += "0 "
-			} else {
+	for row := 0; row < t.height; row++ {
+		s += fmt.Sprintf("%d ", t.height-row)
+		for col := 0; col < t.width; col++ {
+			if t.hasLiberty(root, row*t.width+col) {
 				s += "1 "
-			}
-		}
-		s += fmt.Sprintf(" %d", t.boardsize-row)
-		if row != t.boardsize-1 {
-			s += "\n"
-		}
-	}
-	s += "\n  "
-	for col := 0; col < t.boardsize; col++ {
-		alpha := col + 'A'
-		if alpha >= 'I' {
-			alpha = alpha + 1
-		}
-		s += string(alpha)
-		if col != t.boardsize-1 {
-			s += " "
-		}
-	}
-	s += "\n"
-	return
-}
-
-func (t *GoTracker) maskboard(root int) (s string) {
-	v0, v1 := t.mask[root][0], t.mask[root][1]
-	s += "  "
-	for col := 0; col < t.boardsize; col++ {
-		alpha := col + 'A'
-		if alpha >= 'I' {
-			alpha = alpha + 1
-		}
-		s += string(alpha)
-		if col != t.boardsize-1 {
-			s += " "
-		}
-	}
-	s += "\n"
-	for row := 0; row < t.boardsize; row++ {
-		s += fmt.Sprintf("%d ", t.boardsize-row)
-		for col := 0; col < t.boardsize; col++ {
-			vertex := row*t.boardsize + col
-			var v uint64
-			var bit uint64
-			if vertex < 64 {
-				bit = uint64(64 - vertex - 1)
-				v = v0
 			} else {
-				bit = uint64(64 - (vertex - 64) - 1)
-				v = v1
-			}
-			var mask uint64 = 1 << bit
-			if (mask & v) == 0 {
 				s += "0 "
-			} else {
-				s += "1 "
 			}
 		}
-		s += fmt.Sprintf(" %d", t.boardsize-row)
-		if row != t.boardsize-1 {
+		s += fmt.Sprintf(" %d", t.height-row)
+		if row != t.height-1 {
 			s += "\n"
 		}
 	}
 	s += "\n  "
-	for col := 0; col < t.boardsize; col++ {
+	for col := 0; col < t.width; col++ {
 		alpha := col + 'A'
 		if alpha >= 'I' {
 			alpha = alpha + 1
 		}
 		s += string(alpha)
-		if col != t.boardsize-1 {
+		if col != t.width-1 {
 			s += " "
 		}
 	}
@@ -882,36 +961,36 @@ func (t *GoTracker) maskboard(root int) (s string) {
 
 func (t *GoTracker) libertycountboard() (s string) {
 	s += "  "
-	for col := 0; col < t.boardsize; col++ {
+	for col := 0; col < t.width; col++ {
 		alpha := col + 'A'
 		if alpha >= 'I' {
 			alpha = alpha + 1
 		}
 		s += string(alpha)
-		if col != t.boardsize-1 {
+		if col != t.width-1 {
 			s += " "
 		}
 	}
 	s += "\n"
-	for row := 0; row < t.boardsize; row++ {
-		s += fmt.Sprintf("%d ", t.boardsize-row)
-		for col := 0; col < t.boardsize; col++ {
-			vertex := row*t.boardsize + col
+	for row := 0; row < t.height; row++ {
+		s += fmt.Sprintf("%d ", t.height-row)
+		for col := 0; col < t.width; col++ {
+			vertex := row*t.width + col
 			s += fmt.Sprintf("%d ", t.libs(find(vertex, t.parent)))
 		}
-		s += fmt.Sprintf(" %d", t.boardsize-row)
-		if row != t.boardsize-1 {
+		s += fmt.Sprintf(" %d", t.height-row)
+		if row != t.height-1 {
 			s += "\n"
 		}
 	}
 	s += "\n  "
-	for col := 0; col < t.boardsize; col++ {
+	for col := 0; col < t.width; col++ {
 		alpha := col + 'A'
 		if alpha >= 'I' {
 			alpha = alpha + 1
 		}
 		s += string(alpha)
-		if col != t.boardsize-1 {
+		if col != t.width-1 {
 			s += " "
 		}
 	}
@@ -920,9 +999,9 @@ func (t *GoTracker) libertycountboard() (s string) {
 }
 
 func (t *GoTracker) parentboard() (s string) {
-	for row := 0; row < t.boardsize; row++ {
-		for col := 0; col < t.boardsize; col++ {
-			vertex := row*(t.boardsize) + col
+	for row := 0; row < t.height; row++ {
+		for col := 0; col < t.width; col++ {
+			vertex := row*(t.width) + col
 			if t.board[vertex] == EMPTY {
 				s += ". "
 			} else {
@@ -937,54 +1016,46 @@ func (t *GoTracker) parentboard() (s string) {
 
 func (t *GoTracker) weightboard(color byte) (s string) {
 	s += "  "
-	for col := 0; col < t.boardsize; col++ {
+	for col := 0; col < t.width; col++ {
 		alpha := col + 'A'
 		if alpha >= 'I' {
 			alpha++
 		}
 		s += " " + string(alpha) + " "
-		if col != t.boardsize-1 {
+		if col != t.width-1 {
 			s += " "
 		}
 	}
 	s += "\n"
-	for row := 0; row < t.boardsize; row++ {
-		s += fmt.Sprintf("%d ", t.boardsize-row)
-		for col := 0; col < t.boardsize; col++ {
-			v := row*t.boardsize + col
+	for row := 0; row < t.height; row++ {
+		s += fmt.Sprintf("%d ", t.height-row)
+		for col := 0; col < t.width; col++ {
+			v := row*t.width + col
 			s += fmt.Sprintf("%3.d", t.weights.Get(color, v))
-			if col != t.boardsize-1 {
+			if col != t.width-1 {
 				s += " "
 			}
 		}
-		s += fmt.Sprintf(" %d", t.boardsize-row)
-		if row != t.boardsize-1 {
+		s += fmt.Sprintf(" %d", t.height-row)
+		if row != t.height-1 {
 			s += "\n"
 		}
 	}
 	s += "\n  "
-	for col := 0; col < t.boardsize; col++ {
+	for col := 0; col < t.width; col++ {
 		alpha := col + 'A'
 		if alpha >= 'I' {
 			alpha++
 		}
 		s += string(alpha)
-		if col != t.boardsize-1 {
+		if col != t.width-1 {
 			s += " "
 		}
 	}
 	return
 }
 
-/*
-	mask for packing liberties into 2 64-bit integers
-	given a vertex int, must return the int64 with 1 in the spot for that vertex
-	only works for 9*9 = 81 bits needed
-	64 bits of first int are vertices 0-63
-	17 bits of second int are vertices 64-81
-	last 47 bits of second int are all zero
-*/
-var masks map[int][][4]uint64
+// geometry tables keyed by board_key, built by go_geometry the first time a size is used
 var go_adj map[int][][]int
 var go_neighbors map[int][][][]int
 var go_expert_policy_weights map[uint32]float64
@@ -994,10 +1065,6 @@ var go_hash_mask [9][4]uint32
 func init() {
 	go_adj = make(map[int][][]int)
 	go_neighbors = make(map[int][][][]int)
-	masks = make(map[int][][4]uint64)
-	for boardsize := 4; boardsize <= 19; boardsize++ {
-		setup_go(boardsize)
-	}
 	go_hash_mask = [9][4]uint32{
 		[4]uint32{
 			0x00000000,
@@ -1058,111 +1125,111 @@ func init() {
 	setup_go_expert_policy_weights()
 }
 
-func setup_go(size int) {
-	masks[size] = make([][4]uint64, size*size)
-	for i := 0; i < len(masks[size]); i++ {
-		var m uint64 = 1
-		if i < 64 {
-			masks[size][i][0] = m << uint64(64-i-1)
-		} else {
-			masks[size][i][1] = m << uint64(64-(i-64)-1)
-		}
-		masks[size][i][2] = masks[size][i][0] ^ 0xFFFFFFFFFFFFFFFF
-		masks[size][i][3] = masks[size][i][1] ^ 0xFFFFFFFFFFFFFFFF
+// adjacent and neighboring vertices of a width x height board
+func go_geometry(width, height int) ([][]int, [][][]int) {
+	geometry_lock.Lock()
+	defer geometry_lock.Unlock()
+	key := board_key(width, height)
+	if _, ok := go_adj[key]; !ok {
+		setup_go_adj(width, height)
+		setup_go_neighbors(width, height)
 	}
-	setup_go_adj(size)
-	setup_go_neighbors(size)
+	return go_adj[key], go_neighbors[key]
 }
 
-func setup_go_adj(size int) {
-	go_adj[size] = make([][]int, size*size)
-	for vertex, _ := range go_adj[size] {
-		go_adj[size][vertex] = make([]int, 4)
-		set_go_adj(vertex, size)
+func setup_go_adj(width, height int) {
+	key := board_key(width, height)
+	go_adj[key] = make([][]int, width*height)
+	for vertex, _ := range go_adj[key] {
+		go_adj[key][vertex] = make([]int, 4)
+		set_go_adj(vertex, width, height)
 	}
 }
 
-func set_go_adj(vertex int, size int) {
-	row := vertex / size
-	col := vertex % size
+func set_go_adj(vertex int, width, height int) {
+	adj := go_adj[board_key(width, height)][vertex]
+	row := vertex / width
+	col := vertex % width
 	up_row := row - 1
 	down_row := row + 1
 	left_col := col - 1
 	right_col := col + 1
-	up := up_row*size + col
-	down := down_row*size + col
-	left := row*size + left_col
-	right := row*size + right_col
-	go_adj[size][vertex][UP] = -1
-	go_adj[size][vertex][DOWN] = -1
-	go_adj[size][vertex][LEFT] = -1
-	go_adj[size][vertex][RIGHT] = -1
-	if up_row >= 0 && up_row < size {
-		go_adj[size][vertex][UP] = up
-	}
-	if down_row >= 0 && down_row < size {
-		go_adj[size][vertex][DOWN] = down
-	}
-	if left_col >= 0 && left_col < size {
-		go_adj[size][vertex][LEFT] = left
-	}
-	if right_col >= 0 && right_col < size {
-		go_adj[size][vertex][RIGHT] = right
+	up := up_row*width + col
+	down := down_row*width + col
+	left := row*width + left_col
+	right := row*width + right_col
+	adj[UP] = -1
+	adj[DOWN] = -1
+	adj[LEFT] = -1
+	adj[RIGHT] = -1
+	if up_row >= 0 && up_row < height {
+		adj[UP] = up
+	}
+	if down_row >= 0 && down_row < height {
+		adj[DOWN] = down
+	}
+	if left_col >= 0 && left_col < width {
+		adj[LEFT] = left
+	}
+	if right_col >= 0 && right_col < width {
+		adj[RIGHT] = right
 	}
 }
 
-func setup_go_neighbors(size int) {
-	go_neighbors[size] = make([][][]int, 3)
-	go_neighbors[size][0] = make([][]int, size*size)
-	go_neighbors[size][1] = make([][]int, size*size)
-	for vertex := 0; vertex < size*size; vertex++ {
+func setup_go_neighbors(width, height int) {
+	key := board_key(width, height)
+	size := width * height
+	go_neighbors[key] = make([][][]int, 3)
+	go_neighbors[key][0] = make([][]int, size)
+	go_neighbors[key][1] = make([][]int, size)
+	for vertex := 0; vertex < size; vertex++ {
 		v2 := vertex + 1
-		v3 := vertex + size
-		v4 := vertex + size + 1
-		if (vertex+1)%size == 0 {
+		v3 := vertex + width
+		v4 := vertex + width + 1
+		if (vertex+1)%width == 0 {
 			v2 = -1
 			v4 = -1
 		}
-		if vertex >= (size*size)-size {
+		if vertex >= size-width {
 			v3 = -1
 			v4 = -1
 		}
-		go_neighbors[size][0][vertex] = []int{vertex, v2, v3, v4}
-		set_go_neighbors(size, vertex)
+		go_neighbors[key][0][vertex] = []int{vertex, v2, v3, v4}
+		set_go_neighbors(width, height, vertex)
 	}
 }
 
-func set_go_neighbors(size int, vertex int) {
-	neighbors := go_neighbors[size][1]
+func set_go_neighbors(width, height int, vertex int) {
+	neighbors := go_neighbors[board_key(width, height)][1]
 	neighbors[vertex] = make([]int, 9)
-	neighbors[vertex][0] = vertex - size - 1
-	neighbors[vertex][1] = vertex - size
-	neighbors[vertex][2] = vertex - size + 1
+	neighbors[vertex][0] = vertex - width - 1
+	neighbors[vertex][1] = vertex - width
+	neighbors[vertex][2] = vertex - width + 1
 	neighbors[vertex][3] = vertex - 1
 	neighbors[vertex][4] = vertex
 	neighbors[vertex][5] = vertex + 1
-	neighbors[vertex][6] = vertex + size - 1
-	neighbors[vertex][7] = vertex + size
-	neighbors[vertex][8] = vertex + size + 1
-	if vertex%size == 0 {
+	neighbors[vertex][6] = vertex + width - 1
+	neighbors[vertex][7] = vertex + width
+	neighbors[vertex][8] = vertex + width + 1
+	if vertex%width == 0 {
 		// left
 		neighbors[vertex][0] = -1
 		neighbors[vertex][3] = -1
 		neighbors[vertex][6] = -1
 	}
-	if (vertex+1)%size == 0 {
+	if (vertex+1)%width == 0 {
 		// right
 		neighbors[vertex][2] = -1
 		neighbors[vertex][5] = -1
 		neighbors[vertex][8] = -1
 	}
-	if vertex < size {
+	if vertex < width {
 		// top
 		neighbors[vertex][0] = -1
 		neighbors[vertex][1] = -1
 		neighbors[vertex][2] = -1
 	}
-	if vertex >= (size*size)-size {
+	if vertex >= (width*height)-width {
 		// bottom
 		neighbors[vertex][6] = -1
 		neighbors[vertex][7] = -1
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+	Life and death search for the chain at target, with moves restricted to region
+	depth-first proof-number search as in HexSolver, from the point of view of the
+	player to move, who may always pass
+	the attacker wins by capturing the target, the defender by making two eyes (empty
+	points whose neighbors are all in the target chain, which can never be filled),
+	or when two passes in a row end the fight with the target still on the board
+	positional superko keeps repetitions out of a line of play, but the table ignores
+	how a position was reached, so long ko fights may be misjudged
+*/
+type Tsumego struct {
+	config   *Config
+	target   int
+	defender byte
+	region   []bool
+	table    map[string]*solverEntry
+	Nodes    int
+}
+
+func NewTsumego(t *GoTracker, target int, region []bool, config *Config) *Tsumego {
+	s := new(Tsumego)
+	s.config = config
+	s.target = target
+	s.defender = t.board[target]
+	s.region = region
+	s.table = make(map[string]*solverEntry)
+	return s
+}
+
+/*
+	Status of the target with color to move, "alive" or "dead", and the key move if
+	color gets its way (-1 otherwise, or if passing is enough)
+	"unknown" when TsumegoNodes positions were searched without an answer
+*/
+func (s *Tsumego) Solve(t *GoTracker, color byte) (string, int) {
+	root := s.lookup(t, color)
+	s.mid(t, color, root, SOLVER_INF, SOLVER_INF)
+	if root.phi != 0 && root.delta != 0 {
+		return "unknown", -1
+	}
+	winner := color
+	if root.phi != 0 {
+		winner = Reverse(color)
+	}
+	status := "alive"
+	if winner != s.defender {
+		status = "dead"
+	}
+	if winner != color {
+		return status, -1
+	}
+	for _, vertex := range root.moves {
+		if s.lookup(s.play(t, color, vertex), Reverse(color)).delta == 0 {
+			return status, vertex
+		}
+	}
+	return status, -1
+}
+
+// a copy of t with color played at vertex, Copy doesn't keep the passes so they are carried over
+func (s *Tsumego) play(t *GoTracker, color byte, vertex int) *GoTracker {
+	cp := t.Copy().(*GoTracker)
+	cp.passes = t.passes
+	cp.Play(color, vertex)
+	return cp
+}
+
+func (s *Tsumego) key(t *GoTracker, color byte) string {
+	return string(t.board) + string([]byte{color, byte(t.passes)}) + strconv.Itoa(t.koVertex)
+}
+
+// whether the target chain has two eyes
+func (s *Tsumego) alive(t *GoTracker) bool {
+	root := find(s.target, t.parent)
+	eyes := 0
+	for v := range t.board {
+		if t.board[v] != EMPTY || !t.hasLiberty(root, v) {
+			continue
+		}
+		eye := true
+		for _, adj := range t.adj[v] {
+			if adj != -1 && (t.board[adj] != s.defender || find(adj, t.parent) != root) {
+				eye = false
+			}
+		}
+		if eye {
+			eyes++
+		}
+	}
+	return eyes >= 2
+}
+
+// the entry of a position, evaluated the first time it is seen
+func (s *Tsumego) lookup(t *GoTracker, color byte) *solverEntry {
+	key := s.key(t, color)
+	if e, ok := s.table[key]; ok {
+		return e
+	}
+	e := new(solverEntry)
+	s.table[key] = e
+	s.Nodes++
+	e.phi, e.delta = 1, 1
+	winner := byte(EMPTY)
+	switch {
+	case t.board[s.target] != s.defender:
+		winner = Reverse(s.defender)
+	case t.passes >= 2 || s.alive(t):
+		winner = s.defender
+	}
+	switch winner {
+	case color:
+		e.phi, e.delta = 0, SOLVER_INF
+	case Reverse(color):
+		e.phi, e.delta = SOLVER_INF, 0
+	}
+	return e
+}
+
+// legal moves in the region, then pass
+func (s *Tsumego) expand(t *GoTracker, color byte) []int {
+	moves := make([]int, 0, t.sqsize+1)
+	for v := range t.board {
+		if s.region[v] && t.board[v] == EMPTY && t.Legal(color, v) {
+			moves = append(moves, v)
+		}
+	}
+	return append(moves, -1)
+}
+
+// as HexSolver.mid, giving up once TsumegoNodes positions have been seen
+func (s *Tsumego) mid(t *GoTracker, color byte, e *solverEntry, thphi, thdelta int) {
+	if e.phi >= thphi || e.delta >= thdelta || s.Nodes >= s.config.TsumegoNodes {
+		return
+	}
+	if e.moves == nil {
+		e.moves = s.expand(t, color)
+	}
+	children := make([]*GoTracker, len(e.moves))
+	entries := make([]*solverEntry, len(e.moves))
+	for i, vertex := range e.moves {
+		children[i] = s.play(t, color, vertex)
+		entries[i] = s.lookup(children[i], Reverse(color))
+	}
+	for {
+		phi, delta := SOLVER_INF, 0
+		best, delta2 := -1, SOLVER_INF
+		for i, c := range entries {
+			if c.delta < phi {
+				phi = c.delta
+			}
+			delta += c.phi
+			if delta > SOLVER_INF {
+				delta = SOLVER_INF
+			}
+			if best == -1 || c.delta < entries[best].delta {
+				if best != -1 {
+					delta2 = entries[best].delta
+				}
+				best = i
+			} else if c.delta < delta2 {
+				delta2 = c.delta
+			}
+		}
+		e.phi, e.delta = phi, delta
+		if phi >= thphi || delta >= thdelta || s.Nodes >= s.config.TsumegoNodes {
+			return
+		}
+		childphi := SOLVER_INF
+		if thdelta < SOLVER_INF {
+			childphi = thdelta - (delta - entries[best].phi)
+		}
+		childdelta := delta2 + 1
+		if thphi < childdelta {
+			childdelta = thphi
+		}
+		s.mid(children[best], Reverse(color), entries[best], childphi, childdelta)
+	}
+}
+
+/*
+	Region of a tsumego, a rectangle between two corners such as "A1:E5"
+	an empty string is the box around all the stones, one line larger on each side
+*/
+func TsumegoRegion(t *GoTracker, corners string) ([]bool, os.Error) {
+	var a, b int
+	if corners == "" {
+		a, b = -1, -1
+		for v := range t.board {
+			if t.board[v] != EMPTY {
+				if a == -1 {
+					a = v
+				}
+				b = v
+			}
+		}
+		if a == -1 {
+			return nil, os.NewError("the board is empty")
+		}
+	} else {
+		ends := strings.Split(corners, ":")
+		if len(ends) != 2 || !on_board(t, ends[0]) || !on_board(t, ends[1]) {
+			return nil, os.NewError("region should be two corners on the board like A1:E5, not " + corners)
+		}
+		a, b = t.Atov(ends[0]), t.Atov(ends[1])
+	}
+	top, bottom, left, right := a/t.width, b/t.width, a%t.width, b%t.width
+	if top > bottom {
+		top, bottom = bottom, top
+	}
+	if left > right {
+		left, right = right, left
+	}
+	if corners == "" {
+		// the rows of the stones are in order, their columns are not
+		for v := range t.board {
+			if t.board[v] != EMPTY && v%t.width < left {
+				left = v % t.width
+			} else if t.board[v] != EMPTY && v%t.width > right {
+				right = v % t.width
+			}
+		}
+		top, bottom, left, right = top-1, bottom+1, left-1, right+1
+	}
+	region := make([]bool, t.sqsize)
+	for v := range region {
+		row, col := v/t.width, v%t.width
+		region[v] = row >= top && row <= bottom && col >= left && col <= right
+	}
+	return region, nil
+}
+
+// whether s names a point on the board, as Atov panics on anything else
+func on_board(t *GoTracker, s string) bool {
+	if len(s) < 2 {
+		return false
+	}
+	col := strings.ToUpper(s)[0]
+	if col == 'I' || col < 'A' || col > 'Z' {
+		return false
+	}
+	if col > 'I' {
+		col--
+	}
+	row, err := strconv.Atoi(s[1:])
+	return err == nil && int(col-'A') < t.width && row >= 1 && row <= t.height
+}
+
+// status and key move of the chain at target for color to move, as Tsumego.Solve
+func TsumegoStatus(t *GoTracker, color byte, target, corners string, config *Config) (string, int, os.Error) {
+	if !on_board(t, target) || t.board[t.Atov(target)] == EMPTY {
+		return "", -1, os.NewError(fmt.Sprintf("no stone at %s", target))
+	}
+	region, err := TsumegoRegion(t, corners)
+	if err != nil {
+		return "", -1, err
+	}
+	status, key := NewTsumego(t, t.Atov(target), region, config).Solve(t, color)
+	return status, key, nil
+}
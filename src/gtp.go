@@ -16,6 +16,7 @@ known_command
 list_commands
 quit
 boardsize
+rectangular_boardsize
 clear_board
 komi
 play
@@ -25,14 +26,25 @@ showboard
 time_settings
 time_left
 final_status_list
+book_candidates
+book_enable
+book_last
+solve-state
+tsumego
 gogui-analyze_commands`
 var gogui_commands = `dboard/Visits/visits
 cboard/Territory/territory
 cboard/Weights/weights
 cboard/Book/book
+string/Book Candidates/book_candidates
 cboard/Legal/legal
+gfx/Virtual Connections/vcs
+cboard/Inferior Cells/inferior
 sboard/Stats/stats`
 
+// colors as GTP names them
+var color_names = map[byte]string{BLACK: "black", WHITE: "white"}
+
 func known_command(command_name string) string {
 	for _, s := range strings.Split(supported_commands, "\n") {
 		if strings.TrimSpace(s) == command_name {
@@ -63,9 +75,17 @@ func get_timelimit(timeleft int) int {
 func GTP(config *Config) {
 	var boardsize int
 	var t Tracker
-	var book *Node
+	// book is nil when disabled, from_book is set when the last genmove came from the book
+	book := config.book
+	from_book := false
 	var root *Node
 	var color byte
+	// colors of the moves played, needed to replay the game on cluster workers
+	var colors string
+	var coordinator *Coordinator
+	if config.Distributed {
+		coordinator = NewCoordinator(config)
+	}
 	main_time := -1
 	time_left_color := EMPTY
 	time_left_time := -1
@@ -100,15 +120,47 @@ func GTP(config *Config) {
 			if err != nil {
 				res = fmt.Sprintf("Could not convert %s to integer", args[1])
 				fail = true
+			} else if boardsize < 2 || boardsize > MAX_SIZE {
+				res = "unacceptable size"
+				fail = true
+			} else if config.Othello && !othello_size(boardsize) {
+				res = "unacceptable size"
+				fail = true
+			} else {
+				config.Size = boardsize
+				config.Width, config.Height = 0, 0
+			}
+		case "rectangular_boardsize":
+			if len(args) != 3 {
+				fail = true
+				res = "missing argument"
+			} else if width, err := strconv.Atoi(args[1]); err != nil {
+				fail = true
+				res = fmt.Sprintf("Could not convert %s to integer", args[1])
+			} else if height, err := strconv.Atoi(args[2]); err != nil {
+				fail = true
+				res = fmt.Sprintf("Could not convert %s to integer", args[2])
+			} else if width < 2 || width > MAX_SIZE || height < 2 || height > MAX_SIZE {
+				fail = true
+				res = "unacceptable size"
+			} else if width != height && !RectangularGame(config) {
+				fail = true
+				res = "unacceptable size"
+			} else if config.Othello && !(width == height && othello_size(width)) {
+				fail = true
+				res = "unacceptable size"
+			} else {
+				boardsize = width
+				config.Size = width
+				config.Width, config.Height = width, height
 			}
-			config.Size = boardsize
 		case "clear_board":
 			t = NewTracker(config)
 			color = WHITE
+			colors = ""
 			passcount = 0
 			movecount = 0
 			game_over = false
-			book = config.book
 			root = nil
 		case "komi":
 			new_komi, err := strconv.Atof64(args[1])
@@ -126,6 +178,7 @@ func GTP(config *Config) {
 				color = Atoc(args[1])
 				vertex := t.Atov(args[2])
 				t.Play(color, vertex)
+				colors += Ctoa(color)
 				log.Print(t.String())
 				movecount++
 				if vertex == -1 {
@@ -137,9 +190,6 @@ func GTP(config *Config) {
 					}
 					root = root.Play(color, vertex, t)
 				}
-				if book != nil {
-					book = book.Play(color, vertex, t)
-				}
 			}
 		case "genmove":
 			if len(args) != 2 {
@@ -147,6 +197,7 @@ func GTP(config *Config) {
 				res = "missing argument"
 			} else {
 				saved_timelimit := config.Timelimit
+				from_book = false
 				color = Atoc(args[1])
 				if time_left_time != -1 && color == time_left_color {
 					limit := get_timelimit(time_left_time)
@@ -160,18 +211,22 @@ func GTP(config *Config) {
 					vertex = swap_safe(boardsize)
 				}
 				// Pass if: no time left, game definitely won
-				if vertex == -1 && config.Timelimit != 0 && t.Winner() == EMPTY && !game_over {
+				// Othello has no voluntary pass, so it plays on whatever the prediction
+				if vertex == -1 && config.Timelimit != 0 && t.Winner() == EMPTY && (!game_over || config.Othello) {
+					// positions are looked up every move, so the book is found again after a transposition
 					if book != nil {
-						best := book.Best()
-						if best.Visits > 100 {
-							vertex = best.Vertex
-						}
+						vertex, from_book = book.Move(t, color)
 					}
 					if vertex == -1 {
+						from_book = false
 						if root == nil {
 							root = NewRoot(color, t, config)
 						}
-						genmove(root, t)
+						if coordinator != nil {
+							coordinator.genmove(root, t, colors)
+						} else {
+							genmove(root, t)
+						}
 						if config.Verbose {
 							log.Println(root.String(0, 1, t))
 						}
@@ -187,15 +242,13 @@ func GTP(config *Config) {
 					}
 				}
 				t.Play(color, vertex)
+				colors += Ctoa(color)
 				movecount++
 				log.Print(t.String())
 				if root != nil {
 					root = root.Play(color, vertex, t)
 				}
-				if book != nil {
-					book = book.Play(color, vertex, t)
-				}
-				if (game_over && t.Winner() == Reverse(color)) || (config.Hex && vertex == -1) {
+				if (game_over && t.Winner() == Reverse(color)) || ((config.Hex || config.Havannah || config.Y) && vertex == -1) {
 					res = "resign"
 				} else {
 					res = t.Vtoa(vertex)
@@ -239,16 +292,96 @@ func GTP(config *Config) {
 			res = TerritoryBoard(t.(*GoTracker).weights.Weights(Reverse(color)), 1, t)
 		case "book":
 			value := make([]float64, t.Sqsize())
-			max := 0.0
-			for child := book.Child; child != nil; child = child.Sibling {
-				if child.Visits > max {
-					max = child.Visits
+			if book != nil {
+				moves := book.Candidates(t, Reverse(color))
+				for _, move := range moves {
+					if move.Vertex != -1 {
+						value[move.Vertex] = move.Visits / moves[0].Visits
+					}
 				}
 			}
-			for child := book.Child; child != nil; child = child.Sibling {
-				value[child.Vertex] = child.Visits / max
-			}
 			res = TerritoryBoard(value, 1, t)
+		case "book_candidates":
+			if book == nil {
+				fail = true
+				res = "book is disabled"
+			} else {
+				for _, move := range book.Candidates(t, Reverse(color)) {
+					res += fmt.Sprintf("%s %.0f %.3f\n", t.Vtoa(move.Vertex), move.Visits, book.Winrate(move))
+				}
+				res = strings.TrimSpace(res)
+			}
+		case "book_enable":
+			if len(args) != 2 {
+				fail = true
+				res = "missing argument"
+			} else if enable, err := strconv.Atob(args[1]); err != nil {
+				fail = true
+				res = fmt.Sprintf("Could not convert %s to bool", args[1])
+			} else if enable {
+				book = config.book
+			} else {
+				book = nil
+			}
+		case "book_last":
+			res = fmt.Sprint(from_book)
+		case "vcs":
+			if hex, ok := t.(*HexTracker); ok {
+				res = VCGfx(hex)
+			} else {
+				fail = true
+				res = "virtual connections are only found for hex"
+			}
+		case "inferior":
+			if hex, ok := t.(*HexTracker); ok {
+				res = InferiorBoard(hex, Reverse(color))
+			} else {
+				fail = true
+				res = "inferior cells are only found for hex"
+			}
+		case "solve-state":
+			// the color to move is optional, the player after the last move by default
+			to_move := Reverse(color)
+			if len(args) > 1 {
+				to_move = Atoc(args[1])
+			}
+			if hex, ok := t.(*HexTracker); !ok {
+				fail = true
+				res = "only hex can be solved"
+			} else if to_move != BLACK && to_move != WHITE {
+				fail = true
+				res = "invalid color " + args[1]
+			} else if winner, vertex := NewHexSolver(config).Solve(hex, to_move); winner == EMPTY {
+				res = "unknown"
+			} else if winner == to_move && vertex != -1 {
+				res = fmt.Sprintf("%s %s", color_names[winner], t.Vtoa(vertex))
+			} else {
+				res = color_names[winner]
+			}
+		case "tsumego":
+			// tsumego target [region], for the player after the last move
+			if gotracker, ok := t.(*GoTracker); !ok {
+				fail = true
+				res = "tsumego needs go"
+			} else if len(args) < 2 {
+				fail = true
+				res = "missing target"
+			} else {
+				corners := ""
+				if len(args) > 2 {
+					corners = args[2]
+				}
+				status, vertex, err := TsumegoStatus(gotracker, Reverse(color), args[1], corners, config)
+				switch {
+				case err != nil:
+					fail = true
+					res = err.String()
+				case vertex != -1:
+					res = fmt.Sprintf("%s %s", status, t.Vtoa(vertex))
+				default:
+					res = status
+				}
+			}
 		case "legal":
 			res = LegalBoard(t, map[byte]string{BOTH: "green", BLACK: "black", WHITE: "white", EMPTY: "none"})
 		case "time_settings":
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"container/vector"
+	"fmt"
+	"log"
+	"rand"
+	"strconv"
+	"strings"
+)
+
+/*
+	Havannah on a hexagonal board with config.Size cells per side
+	the board is stored like a Hex board of width 2*Size-1, with the same neighbors,
+	cells outside the hexagon are ILLEGAL
+	a player wins with a bridge (a group touching two corners), a fork (a group
+	touching three edges, corners don't count as edges) or a ring (a loop around
+	at least one cell)
+	a full board with no win is a draw, the winner is then BOTH
+*/
+type HavannahTracker struct {
+	side    int
+	width   int
+	sqsize  int
+	parent  []int
+	rank    []int
+	board   []byte
+	weights *WeightTree
+	// corners and edges touched by each group, bitmasks kept at the group's root
+	corners []byte
+	edges   []byte
+	winner  byte
+	// bridge, fork or ring
+	win       string
+	empty     int
+	played    []byte
+	adj       []int
+	neighbors [][]int
+	moves     *vector.IntVector
+	config    *Config
+}
+
+func NewHavannahTracker(config *Config) *HavannahTracker {
+	t := new(HavannahTracker)
+
+	t.side = config.Size
+	t.width = 2*t.side - 1
+	t.sqsize = t.width * t.width
+	adj, neighbors, corners, edges := havannah_geometry(t.side)
+	t.adj = adj
+	t.neighbors = neighbors
+	t.board = make([]byte, t.sqsize)
+	t.parent = make([]int, t.sqsize)
+	t.rank = make([]int, t.sqsize)
+	t.corners = make([]byte, t.sqsize)
+	t.edges = make([]byte, t.sqsize)
+	copy(t.corners, corners)
+	copy(t.edges, edges)
+	t.weights = NewWeightTree(t.sqsize)
+	for i := 0; i < t.sqsize; i++ {
+		t.parent[i] = i
+		t.rank[i] = 1
+		if t.onboard(i) {
+			t.weights.Set(BLACK, i, INIT_WEIGHT)
+			t.weights.Set(WHITE, i, INIT_WEIGHT)
+			t.empty++
+		} else {
+			t.board[i] = ILLEGAL
+		}
+	}
+
+	t.winner = EMPTY
+
+	t.played = make([]byte, t.sqsize)
+
+	t.moves = new(vector.IntVector)
+
+	t.config = config
+
+	return t
+}
+
+func (t *HavannahTracker) Copy() Tracker {
+	cp := new(HavannahTracker)
+
+	cp.side = t.side
+	cp.width = t.width
+	cp.sqsize = t.sqsize
+	cp.adj = t.adj
+	cp.neighbors = t.neighbors
+	cp.board = make([]byte, cp.sqsize)
+	cp.parent = make([]int, cp.sqsize)
+	cp.rank = make([]int, cp.sqsize)
+	cp.corners = make([]byte, cp.sqsize)
+	cp.edges = make([]byte, cp.sqsize)
+	copy(cp.board, t.board)
+	copy(cp.parent, t.parent)
+	copy(cp.rank, t.rank)
+	copy(cp.corners, t.corners)
+	copy(cp.edges, t.edges)
+	cp.weights = t.weights.Copy()
+
+	cp.winner = t.winner
+	cp.win = t.win
+	cp.empty = t.empty
+
+	cp.played = make([]byte, cp.sqsize)
+
+	cp.moves = new(vector.IntVector)
+	*cp.moves = t.moves.Copy()
+
+	cp.config = t.config
+
+	return cp
+}
+
+func (t *HavannahTracker) onboard(vertex int) bool {
+	return havannah_onboard(t.side, vertex/t.width, vertex%t.width)
+}
+
+/*
+	Whether a stone of color on vertex closes a ring: walking around vertex, two
+	separate runs of color's stones belong to the same group
+	the cells between the runs are then enclosed on one side, unless that side is
+	off the board, which can only be true of one side
+	must be called before the stone is joined to its neighbors
+*/
+func (t *HavannahTracker) ring(color byte, vertex int) bool {
+	adj := t.adj[vertex*6 : (vertex+1)*6]
+	start := -1
+	for i := range adj {
+		if adj[i] == -1 || t.board[adj[i]] != color {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return false
+	}
+	var roots [3]int
+	runs := 0
+	in_run := false
+	for k := 1; k <= 6; k++ {
+		n := adj[(start+k)%6]
+		if n != -1 && t.board[n] == color {
+			if !in_run {
+				root := find(n, t.parent)
+				for j := 0; j < runs; j++ {
+					if roots[j] == root {
+						return true
+					}
+				}
+				roots[runs] = root
+				runs++
+			}
+			in_run = true
+		} else {
+			in_run = false
+		}
+	}
+	return false
+}
+
+func bits(mask byte) (n int) {
+	for ; mask != 0; mask &= mask - 1 {
+		n++
+	}
+	return
+}
+
+func (t *HavannahTracker) Play(color byte, vertex int) {
+	if vertex != -1 {
+		if t.board[vertex] != EMPTY {
+			log.Println(t.String())
+			log.Println(Ctoa(color), t.Vtoa(vertex))
+			panic("play on non-empty vertex")
+		}
+		ring := t.ring(color, vertex)
+		root := vertex
+		for i := 0; i < 6; i++ {
+			adj := t.adj[vertex*6+i]
+			if adj == -1 || t.board[adj] != color {
+				continue
+			}
+			adj = find(adj, t.parent)
+			if adj != root {
+				corners, edges := t.corners[root]|t.corners[adj], t.edges[root]|t.edges[adj]
+				root = fastUnion(root, adj, t.parent, t.rank)
+				t.corners[root], t.edges[root] = corners, edges
+			}
+		}
+		t.board[vertex] = color
+		t.empty--
+		// cannot play on occupied vertex
+		t.weights.Set(BLACK, vertex, 0)
+		t.weights.Set(WHITE, vertex, 0)
+		if t.config.PlayoutProbs && t.config.policy_weights != nil {
+			t.updateNeighborWeights(vertex)
+		}
+
+		if t.played[vertex] == EMPTY {
+			t.played[vertex] = color
+		}
+
+		switch {
+		case ring:
+			t.winner, t.win = color, "ring"
+		case bits(t.corners[root]) >= 2:
+			t.winner, t.win = color, "bridge"
+		case bits(t.edges[root]) >= 3:
+			t.winner, t.win = color, "fork"
+		case t.empty == 0:
+			t.winner = BOTH
+		}
+	}
+	t.moves.Push(vertex)
+}
+
+func (t *HavannahTracker) updateNeighborWeights(vertex int) {
+	for i := range t.neighbors[vertex] {
+		neighbor := t.neighbors[vertex][i]
+		if neighbor != -1 && t.board[neighbor] == EMPTY {
+			t.updateWeights(BLACK, neighbor, neighbor)
+			t.updateWeights(WHITE, neighbor, neighbor)
+			t.updateWeights(BLACK, vertex, neighbor)
+			t.updateWeights(WHITE, vertex, neighbor)
+		}
+	}
+}
+
+func (t *HavannahTracker) updateWeights(color byte, v1, v2 int) {
+	weight := t.get_pattern_weight(color, v1) * t.weights.Get(color, v2)
+	if weight == 0 {
+		weight = 1
+	}
+	t.weights.Set(color, v2, weight)
+}
+
+func (t *HavannahTracker) get_pattern_weight(color byte, vertex int) float64 {
+	hash := havannah_min_hash[hex_hash(color, t.board, t.neighbors[vertex])]
+	return t.config.policy_weights.Get(hash)
+}
+
+func (t *HavannahTracker) suggestion(color byte, last int) int {
+	if last == -1 || !t.config.PlayoutSuggest {
+		return -1
+	}
+	if t.config.policy_weights == nil && !t.config.PlayoutSuggestUniform {
+		return -1
+	}
+	var weights [6]float64
+	weightSum := 0.0
+	for i := 0; i < 6; i++ {
+		n := t.neighbors[last][i]
+		if n != -1 && t.board[n] == EMPTY {
+			if t.config.PlayoutSuggestUniform {
+				weights[i] = 1
+			} else {
+				hash := havannah_min_hash[hex_hash(color, t.board, t.neighbors[n])]
+				hash |= LOCAL_PATTERN
+				weights[i] = t.config.policy_weights.Get(hash)
+			}
+			weightSum += weights[i]
+		}
+	}
+	if weightSum > 0 {
+		r := rand.Float64() * weightSum
+		for i := range weights {
+			if weights[i] > 0 {
+				r -= weights[i]
+				if r <= 0 {
+					return t.neighbors[last][i]
+				}
+			}
+		}
+	}
+	return -1
+}
+
+func (t *HavannahTracker) Playout(color byte) {
+	vertex := -1
+	for t.winner == EMPTY {
+		vertex = t.suggestion(color, vertex)
+		if vertex == -1 {
+			vertex = t.weights.Rand(color)
+		}
+		if t.config.VeryVerbose {
+			log.Println(Ctoa(color) + t.Vtoa(vertex))
+		}
+		t.Play(color, vertex)
+		if t.config.VeryVerbose {
+			log.Println(t.String())
+		}
+		if t.config.Verify {
+			t.Verify()
+		}
+		color = Reverse(color)
+	}
+	if t.config.VeryVerbose {
+		log.Println("FINAL: " + Ctoa(t.winner) + " " + t.win)
+	}
+}
+
+func (t *HavannahTracker) WasPlayed(color byte, vertex int) bool {
+	return t.played[vertex] == color
+}
+
+func (t *HavannahTracker) Legal(color byte, vertex int) bool {
+	return vertex != -1 && t.board[vertex] == EMPTY
+}
+
+func (t *HavannahTracker) Score(Komi float64) (float64, float64) {
+	switch t.winner {
+	case BLACK:
+		return 1, 0
+	case WHITE:
+		return 0, 1
+	case BOTH:
+		return 0.5, 0.5
+	}
+	return 0, 0
+}
+
+func (t *HavannahTracker) Winner() byte {
+	return t.winner
+}
+
+func (t *HavannahTracker) SetKomi(Komi float64) {
+
+}
+
+func (t *HavannahTracker) GetKomi() float64 {
+	return 0
+}
+
+// the board array is a square of side 2*Size-1
+func (t *HavannahTracker) Width() int {
+	return t.width
+}
+
+func (t *HavannahTracker) Height() int {
+	return t.width
+}
+
+func (t *HavannahTracker) Sqsize() int {
+	return t.sqsize
+}
+
+func (t *HavannahTracker) Board() []byte {
+	return t.board
+}
+
+func (t *HavannahTracker) Territory(color byte) []float64 {
+	territory := make([]float64, t.sqsize)
+	for i := range t.board {
+		if t.board[i] == color {
+			territory[i] = 1
+		}
+	}
+	return territory
+}
+
+// check every group's corner and edge masks and the empty count
+func (t *HavannahTracker) Verify() {
+	_, _, cell_corners, cell_edges := havannah_geometry(t.side)
+	corners := make([]byte, t.sqsize)
+	edges := make([]byte, t.sqsize)
+	empty := 0
+	for i := range t.board {
+		switch t.board[i] {
+		case EMPTY:
+			empty++
+		case BLACK, WHITE:
+			root := find(i, t.parent)
+			corners[root] |= cell_corners[i]
+			edges[root] |= cell_edges[i]
+		}
+	}
+	for i := range t.board {
+		if (t.board[i] == BLACK || t.board[i] == WHITE) && find(i, t.parent) == i &&
+			(corners[i] != t.corners[i] || edges[i] != t.edges[i]) {
+			panic("wrong corners or edges for group at " + t.Vtoa(i))
+		}
+	}
+	if empty != t.empty {
+		panic("wrong empty count")
+	}
+}
+
+func (t *HavannahTracker) Adj(vertex int) []int {
+	return t.adj[vertex*6 : (vertex+1)*6]
+}
+
+func (t *HavannahTracker) Moves() *vector.IntVector {
+	return t.moves
+}
+
+func (t *HavannahTracker) Vtoa(v int) string {
+	if v == -1 {
+		return "PASS"
+	}
+	alpha, num := v%t.width, v/t.width
+	num++
+	alpha = alpha + 'A'
+	if alpha >= 'I' {
+		alpha++
+	}
+	return fmt.Sprintf("%s%d", string(alpha), num)
+}
+
+func (t *HavannahTracker) Atov(s string) int {
+	if s == "PASS" || s == "pass" {
+		return -1
+	}
+	// pull apart into alpha and int pair
+	col := byte(strings.ToUpper(s)[0])
+	row, err := strconv.Atoi(s[1:len(s)])
+	row--
+	if col >= 'I' {
+		col--
+	}
+	if err != nil {
+		panic("Failed to convert string to vertex")
+	}
+	return row*t.width + int(col-'A')
+}
+
+// cell (row, col) is drawn 2*col+row characters in, which lays the board out as a hexagon
+func (t *HavannahTracker) String() (s string) {
+	for row := 0; row < t.width; row++ {
+		s += fmt.Sprintf("%2d ", row+1)
+		for x := t.side - 1; x < 2*(t.width-1)+row; x++ {
+			col := x - row
+			if col%2 == 0 && havannah_onboard(t.side, row, col/2) {
+				s += Ctoa(t.board[row*t.width+col/2])
+			} else {
+				s += " "
+			}
+		}
+		s = strings.TrimRight(s, " ")
+		if row != t.width-1 {
+			s += "\n"
+		}
+	}
+	return
+}
+
+// keyed by side, built by havannah_geometry the first time a size is used
+var havannah_adj map[int][]int
+var havannah_neighbors map[int][][]int
+var havannah_corners map[int][]byte
+var havannah_edges map[int][]byte
+var havannah_min_hash map[uint32]uint32
+
+func init() {
+	havannah_adj = make(map[int][]int)
+	havannah_neighbors = make(map[int][][]int)
+	havannah_corners = make(map[int][]byte)
+	havannah_edges = make(map[int][]byte)
+	setup_havannah_min_hash()
+}
+
+// adjacent cells, pattern neighbors, and corner and edge masks of each cell
+func havannah_geometry(side int) ([]int, [][]int, []byte, []byte) {
+	geometry_lock.Lock()
+	defer geometry_lock.Unlock()
+	if _, ok := havannah_adj[side]; !ok {
+		setup_havannah(side)
+	}
+	return havannah_adj[side], havannah_neighbors[side], havannah_corners[side], havannah_edges[side]
+}
+
+// cells of the hexagon of the given side, in a board of width 2*side-1
+func havannah_onboard(side, row, col int) bool {
+	width := 2*side - 1
+	return row >= 0 && row < width && col >= 0 && col < width &&
+		row+col >= side-1 && row+col <= 3*(side-1)
+}
+
+func setup_havannah(side int) {
+	width := 2*side - 1
+	s := width * width
+	havannah_adj[side] = make([]int, s*6)
+	havannah_neighbors[side] = make([][]int, s)
+	havannah_corners[side] = make([]byte, s)
+	havannah_edges[side] = make([]byte, s)
+	// the six directions in order around a cell, as in setup_hex_neighbors
+	dirs := [6][2]int{
+		[2]int{-1, 0},
+		[2]int{-1, 1},
+		[2]int{0, 1},
+		[2]int{1, 0},
+		[2]int{1, -1},
+		[2]int{0, -1},
+	}
+	corners := [6][2]int{
+		[2]int{0, side - 1},
+		[2]int{0, width - 1},
+		[2]int{side - 1, width - 1},
+		[2]int{width - 1, side - 1},
+		[2]int{width - 1, 0},
+		[2]int{side - 1, 0},
+	}
+	for row := 0; row < width; row++ {
+		for col := 0; col < width; col++ {
+			v := row*width + col
+			havannah_neighbors[side][v] = make([]int, 7)
+			havannah_neighbors[side][v][6] = v
+			for i := range dirs {
+				r, c := row+dirs[i][0], col+dirs[i][1]
+				n := -1
+				if havannah_onboard(side, r, c) {
+					n = r*width + c
+				}
+				havannah_adj[side][v*6+i] = n
+				havannah_neighbors[side][v][i] = n
+			}
+			if !havannah_onboard(side, row, col) {
+				continue
+			}
+			corner := false
+			for i := range corners {
+				if row == corners[i][0] && col == corners[i][1] {
+					havannah_corners[side][v] = 1 << uint(i)
+					corner = true
+				}
+			}
+			if corner {
+				continue
+			}
+			// edges in the same order as the corners they start from
+			switch {
+			case row == 0:
+				havannah_edges[side][v] = 1 << 0
+			case col == width-1:
+				havannah_edges[side][v] = 1 << 1
+			case row+col == 3*(side-1):
+				havannah_edges[side][v] = 1 << 2
+			case row == width-1:
+				havannah_edges[side][v] = 1 << 3
+			case col == 0:
+				havannah_edges[side][v] = 1 << 4
+			case row+col == side-1:
+				havannah_edges[side][v] = 1 << 5
+			}
+		}
+	}
+}
+
+// like setup_hex_min_hash, but with all 12 symmetries of the hexagon: 6 rotations, each optionally mirrored
+func setup_havannah_min_hash() {
+	havannah_min_hash = make(map[uint32]uint32)
+	symmetries := make([][]int, 12)
+	for k := 0; k < 6; k++ {
+		symmetries[k] = make([]int, 7)
+		symmetries[k+6] = make([]int, 7)
+		for i := 0; i < 6; i++ {
+			symmetries[k][i] = (i + k) % 6
+			symmetries[k+6][i] = (k - i + 6) % 6
+		}
+		symmetries[k][6] = 6
+		symmetries[k+6][6] = 6
+	}
+	for board := []byte{EMPTY, EMPTY, EMPTY, EMPTY, EMPTY, EMPTY, EMPTY}; odometer(board, len(board)-1); {
+		black_min_hash := ^uint32(0)
+		white_min_hash := ^uint32(0)
+		for x := range symmetries {
+			black_hash := hex_hash(BLACK, board, symmetries[x])
+			if black_hash < black_min_hash {
+				black_min_hash = black_hash
+			}
+			white_hash := hex_hash(WHITE, board, symmetries[x])
+			if white_hash < white_min_hash {
+				white_min_hash = white_hash
+			}
+		}
+		for x := range symmetries {
+			havannah_min_hash[hex_hash(BLACK, board, symmetries[x])] = black_min_hash
+			havannah_min_hash[hex_hash(WHITE, board, symmetries[x])] = white_min_hash
+		}
+	}
+}
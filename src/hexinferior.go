@@ -0,0 +1,142 @@
+package main
+
+// kinds of inferior cells reported by HexTracker.Inferior, 0 for the others
+const (
+	HEX_DEAD = 1 + iota
+	HEX_CAPTURED_BLACK
+	HEX_CAPTURED_WHITE
+	HEX_DOMINATED
+)
+
+// color of a point next to a cell: stones are their own color, sides their owner's
+func (t *HexTracker) ring_color(point int) byte {
+	switch {
+	case point < t.sqsize:
+		return t.board[point]
+	case point == t.SIDE_UP || point == t.SIDE_DOWN:
+		return BLACK
+	}
+	return WHITE
+}
+
+/*
+	Whether the empty cell v can't change the winner whoever plays it, by the
+	neighborhood patterns of dead cell analysis:
+	four neighbors in a row of one color
+	three in a row of one color, and the one opposite the middle of the other
+	two in a row of one color facing two in a row of the other
+*/
+func (t *HexTracker) dead(v int) bool {
+	var ring [6]byte
+	for i := range hex_ring {
+		ring[i] = t.ring_color(t.adj[v*6+hex_ring[i]])
+	}
+	for i := 0; i < 6; i++ {
+		for _, color := range []byte{BLACK, WHITE} {
+			other := Reverse(color)
+			if ring[i] != color || ring[(i+1)%6] != color {
+				continue
+			}
+			if ring[(i+2)%6] == color && (ring[(i+3)%6] == color || ring[(i+4)%6] == other) {
+				return true
+			}
+			if ring[(i+3)%6] == other && ring[(i+4)%6] == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// whether v is dead once color has played at w
+func (t *HexTracker) dead_after(color byte, w, v int) bool {
+	t.board[w] = color
+	dead := t.dead(v)
+	t.board[w] = EMPTY
+	return dead
+}
+
+/*
+	Inferior cells of the position for color to move, indexed by vertex
+	dead cells never matter
+	captured cells come in pairs of neighbors, where the captor playing either
+	makes the other dead, so the captor can answer the opponent in one with the
+	other, and may fill both
+	a cell is dominated if color playing a neighbor that is not inferior makes it
+	dead, that neighbor is then at least as good a move
+	none of them need to be searched, dead and captured cells may be filled in
+*/
+func (t *HexTracker) Inferior(color byte) []byte {
+	inferior := make([]byte, t.sqsize)
+	for v := range t.board {
+		if t.board[v] == EMPTY && t.dead(v) {
+			inferior[v] = HEX_DEAD
+		}
+	}
+	for _, captor := range []byte{BLACK, WHITE} {
+		kind := byte(HEX_CAPTURED_BLACK)
+		if captor == WHITE {
+			kind = HEX_CAPTURED_WHITE
+		}
+		for v := range t.board {
+			if t.board[v] != EMPTY || inferior[v] != 0 {
+				continue
+			}
+			for i := 0; i < 6; i++ {
+				w := t.adj[v*6+i]
+				if w < v || w >= t.sqsize || t.board[w] != EMPTY || inferior[w] != 0 {
+					continue
+				}
+				if t.dead_after(captor, v, w) && t.dead_after(captor, w, v) {
+					inferior[v], inferior[w] = kind, kind
+					break
+				}
+			}
+		}
+	}
+	for v := range t.board {
+		if t.board[v] != EMPTY || inferior[v] != 0 {
+			continue
+		}
+		for i := 0; i < 6; i++ {
+			w := t.adj[v*6+i]
+			if w < t.sqsize && t.board[w] == EMPTY && inferior[w] == 0 && t.dead_after(color, w, v) {
+				inferior[v] = HEX_DOMINATED
+				break
+			}
+		}
+	}
+	return inferior
+}
+
+// fill dead cells with color and captured cells with their captor, which doesn't change the winner
+func (t *HexTracker) fill(color byte) {
+	for v, kind := range t.Inferior(color) {
+		switch kind {
+		case HEX_DEAD:
+			t.Play(color, v)
+		case HEX_CAPTURED_BLACK:
+			t.Play(BLACK, v)
+		case HEX_CAPTURED_WHITE:
+			t.Play(WHITE, v)
+		}
+	}
+}
+
+// gogui cboard of the inferior cells for color to move
+func InferiorBoard(t *HexTracker, color byte) (s string) {
+	names := []string{"none", "gray", "blue", "red", "yellow"}
+	inferior := t.Inferior(color)
+	for row := 0; row < t.height; row++ {
+		for col := 0; col < t.width; col++ {
+			s += names[inferior[row*t.width+col]]
+			if col != t.width-1 {
+				s += " "
+			}
+		}
+		if row != t.height-1 {
+			s += "\n"
+		}
+	}
+	return
+}
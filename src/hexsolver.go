@@ -0,0 +1,169 @@
+package main
+
+// proof and disproof numbers at or over this are infinite
+const SOLVER_INF = 1 << 30
+
+// proof and disproof numbers of a position, from the point of view of the player to move
+type solverEntry struct {
+	// cost of proving the player to move wins, and of proving they lose
+	phi, delta int
+	// the moves worth trying, nil until the position is expanded
+	moves []int
+	// with SolveVC, the cells the player to move must play in to stop the opponent's SCs, nil if there are none
+	mustplay Carrier
+}
+
+/*
+	Exact Hex solver, depth-first proof-number search (DFPN) in negamax form
+	phi of a position is the least delta of its children, delta the sum of their phis
+	the child with the least delta is searched until the thresholds of its parent
+	are crossed, so the search stays in one subtree as long as it looks best
+	the transposition table is keyed by the board itself rather than its hash, so
+	solutions can be trusted as ground truth
+	with SolveVC, a player with a VC between their sides has won, and the player to
+	move must play in the carriers of the opponent's SCs between their sides
+	the search gives up once SolveNodes positions are in the table, which bounds its
+	time and memory on boards too large to solve
+*/
+type HexSolver struct {
+	config *Config
+	table  map[string]*solverEntry
+	Nodes  int
+}
+
+func NewHexSolver(config *Config) *HexSolver {
+	s := new(HexSolver)
+	s.config = config
+	s.table = make(map[string]*solverEntry)
+	return s
+}
+
+// winner of the position with color to move, and a winning move when that is color, -1 otherwise
+// EMPTY when SolveNodes positions were searched without an answer
+func (s *HexSolver) Solve(t *HexTracker, color byte) (byte, int) {
+	root := s.lookup(t, color, true)
+	s.mid(t, color, root, SOLVER_INF, SOLVER_INF)
+	if root.phi != 0 && root.delta != 0 {
+		return EMPTY, -1
+	}
+	if root.phi != 0 {
+		return Reverse(color), -1
+	}
+	for _, vertex := range root.moves {
+		cp := t.Copy().(*HexTracker)
+		cp.Play(color, vertex)
+		if s.lookup(cp, Reverse(color), false).delta == 0 {
+			return color, vertex
+		}
+	}
+	// won before moving, which only happens when the game is already over
+	return color, -1
+}
+
+func (s *HexSolver) key(t *HexTracker, color byte) string {
+	return string(t.board) + string(color)
+}
+
+// the entry of a position, evaluated the first time it is seen
+func (s *HexSolver) lookup(t *HexTracker, color byte, root bool) *solverEntry {
+	key := s.key(t, color)
+	if e, ok := s.table[key]; ok {
+		return e
+	}
+	e := new(solverEntry)
+	s.table[key] = e
+	s.Nodes++
+	e.phi, e.delta = 1, 1
+	switch {
+	case t.winner == color:
+		e.phi, e.delta = 0, SOLVER_INF
+	case t.winner != EMPTY:
+		e.phi, e.delta = SOLVER_INF, 0
+	case s.config.SolveVC:
+		other := Reverse(color)
+		up, down := t.sides(other)
+		vcs := t.VirtualConnections(other)
+		if vcs.Connected(up, down) != nil {
+			e.phi, e.delta = SOLVER_INF, 0
+			break
+		}
+		for _, c := range vcs.SemiConnections(up, down) {
+			if e.mustplay == nil {
+				e.mustplay = c.Carrier
+			} else {
+				e.mustplay = e.mustplay.And(c.Carrier)
+			}
+		}
+		// the root is expanded even if it is won, to find the winning move
+		up, down = t.sides(color)
+		if !root && t.VirtualConnections(color).Connected(up, down) != nil {
+			e.phi, e.delta = 0, SOLVER_INF
+		}
+	}
+	return e
+}
+
+// empty cells, only those in mustplay if there is one
+func (s *HexSolver) expand(t *HexTracker, e *solverEntry) []int {
+	moves := make([]int, 0, t.sqsize)
+	for v := range t.board {
+		if t.board[v] == EMPTY && (e.mustplay == nil || e.mustplay.Has(v)) {
+			moves = append(moves, v)
+		}
+	}
+	return moves
+}
+
+// multiple iterative deepening: search e until its phi reaches thphi or its delta thdelta
+func (s *HexSolver) mid(t *HexTracker, color byte, e *solverEntry, thphi, thdelta int) {
+	if e.phi >= thphi || e.delta >= thdelta || s.Nodes >= s.config.SolveNodes {
+		return
+	}
+	if e.moves == nil {
+		e.moves = s.expand(t, e)
+	}
+	children := make([]*HexTracker, len(e.moves))
+	entries := make([]*solverEntry, len(e.moves))
+	for i, vertex := range e.moves {
+		children[i] = t.Copy().(*HexTracker)
+		children[i].Play(color, vertex)
+		entries[i] = s.lookup(children[i], Reverse(color), false)
+	}
+	for {
+		// no move left in mustplay means the opponent can't be stopped
+		phi, delta := SOLVER_INF, 0
+		best, delta2 := -1, SOLVER_INF
+		for i, c := range entries {
+			if c.delta < phi {
+				phi = c.delta
+			}
+			// int is 32 bits, so the sum is capped as it goes
+			delta += c.phi
+			if delta > SOLVER_INF {
+				delta = SOLVER_INF
+			}
+			if best == -1 || c.delta < entries[best].delta {
+				if best != -1 {
+					delta2 = entries[best].delta
+				}
+				best = i
+			} else if c.delta < delta2 {
+				delta2 = c.delta
+			}
+		}
+		e.phi, e.delta = phi, delta
+		if phi >= thphi || delta >= thdelta || s.Nodes >= s.config.SolveNodes {
+			return
+		}
+		// the other children's phis are finite, or delta would have crossed thdelta
+		childphi := SOLVER_INF
+		if thdelta < SOLVER_INF {
+			childphi = thdelta - (delta - entries[best].phi)
+		}
+		childdelta := delta2 + 1
+		if thphi < childdelta {
+			childdelta = thphi
+		}
+		s.mid(children[best], Reverse(color), entries[best], childphi, childdelta)
+	}
+}
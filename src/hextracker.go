@@ -11,7 +11,8 @@ import (
 )
 
 type HexTracker struct {
-	boardsize                                 int
+	width                                     int
+	height                                    int
 	sqsize                                    int
 	parent                                    []int
 	rank                                      []int
@@ -29,10 +30,9 @@ type HexTracker struct {
 func NewHexTracker(config *Config) *HexTracker {
 	t := new(HexTracker)
 
-	t.boardsize = config.Size
-	t.sqsize = t.boardsize * t.boardsize
-	t.adj = hex_adj[t.boardsize]
-	t.neighbors = hex_neighbors[t.boardsize]
+	t.width, t.height = config.Dimensions()
+	t.sqsize = t.width * t.height
+	t.adj, t.neighbors = hex_geometry(t.width, t.height)
 	t.SIDE_UP = t.sqsize
 	t.SIDE_DOWN = t.sqsize + 1
 	t.SIDE_LEFT = t.sqsize + 2
@@ -69,7 +69,8 @@ func NewHexTracker(config *Config) *HexTracker {
 func (t *HexTracker) Copy() Tracker {
 	cp := new(HexTracker)
 
-	cp.boardsize = t.boardsize
+	cp.width = t.width
+	cp.height = t.height
 	cp.adj = t.adj
 	cp.neighbors = t.neighbors
 	cp.SIDE_UP = t.SIDE_UP
@@ -169,6 +170,10 @@ func (t *HexTracker) suggestion(color byte, last int) int {
 	if last == -1 || !t.config.PlayoutSuggest {
 		return -1
 	}
+	// playing without weights, e.g. as the training reference
+	if t.config.policy_weights == nil && !t.config.PlayoutSuggestUniform {
+		return -1
+	}
 	var weights [6]float64
 	weightSum := 0.0
 	for i := range t.neighbors[1][last] {
@@ -178,7 +183,7 @@ func (t *HexTracker) suggestion(color byte, last int) int {
 				weights[i] = 1
 			} else {
 				hash := hex_min_hash[hex_hash(color, t.board, t.neighbors[1][n])]
-				hash |= 1 << 30
+				hash |= LOCAL_PATTERN
 				weights[i] = t.config.policy_weights.Get(hash)
 			}
 			weightSum += weights[i]
@@ -198,10 +203,73 @@ func (t *HexTracker) suggestion(color byte, last int) int {
 	return -1
 }
 
+// the neighbors of a cell in order around it, as adj directions
+var hex_ring = [6]int{UP_LEFT, UP_RIGHT, RIGHT, DOWN_RIGHT, DOWN_LEFT, LEFT}
+
+// whether point, a cell or a side, is one of color's stones or sides
+func (t *HexTracker) owns(color byte, point int) bool {
+	switch {
+	case point < 0:
+		return false
+	case point < t.sqsize:
+		return t.board[point] == color
+	case color == BLACK:
+		return point == t.SIDE_UP || point == t.SIDE_DOWN
+	}
+	return point == t.SIDE_LEFT || point == t.SIDE_RIGHT
+}
+
+// the two sides color connects
+func (t *HexTracker) sides(color byte) (int, int) {
+	if color == BLACK {
+		return t.SIDE_UP, t.SIDE_DOWN
+	}
+	return t.SIDE_LEFT, t.SIDE_RIGHT
+}
+
+/*
+	The cell saving a bridge (or a second row edge template) of color that the
+	opponent's move at last intruded into, -1 if there is none
+	two points two steps apart around last are bridged through the cell between them
+*/
+func (t *HexTracker) save_bridge(color byte, last int) int {
+	if last == -1 || !t.config.PlayoutBridges {
+		return -1
+	}
+	var saves [6]int
+	n := 0
+	for i := range hex_ring {
+		a := t.adj[last*6+hex_ring[i]]
+		mid := t.adj[last*6+hex_ring[(i+1)%6]]
+		b := t.adj[last*6+hex_ring[(i+2)%6]]
+		if mid < 0 || mid >= t.sqsize || t.board[mid] != EMPTY || !t.owns(color, a) || !t.owns(color, b) {
+			continue
+		}
+		if find(a, t.parent) != find(b, t.parent) {
+			saves[n] = mid
+			n++
+		}
+	}
+	if n == 0 {
+		return -1
+	}
+	return saves[rand.Intn(n)]
+}
+
 func (t *HexTracker) Playout(color byte) {
+	if t.config.Inferior {
+		t.fill(color)
+		if t.winner != EMPTY {
+			return
+		}
+	}
 	vertex := -1
 	for {
-		vertex = t.suggestion(color, vertex)
+		last := vertex
+		vertex = t.save_bridge(color, last)
+		if vertex == -1 {
+			vertex = t.suggestion(color, last)
+		}
 		if vertex == -1 {
 			vertex = t.weights.Rand(color)
 		}
@@ -254,8 +322,12 @@ func (t *HexTracker) GetKomi() float64 {
 	return 0
 }
 
-func (t *HexTracker) Boardsize() int {
-	return t.boardsize
+func (t *HexTracker) Width() int {
+	return t.width
+}
+
+func (t *HexTracker) Height() int {
+	return t.height
 }
 
 func (t *HexTracker) Sqsize() int {
@@ -291,7 +363,7 @@ func (t *HexTracker) Vtoa(v int) string {
 	if v == -1 {
 		return "PASS"
 	}
-	alpha, num := v%t.boardsize, v/t.boardsize
+	alpha, num := v%t.width, v/t.width
 	num++
 	alpha = alpha + 'A'
 	if alpha >= 'I' {
@@ -314,51 +386,51 @@ func (t *HexTracker) Atov(s string) int {
 	if err != nil {
 		panic("Failed to convert string to vertex")
 	}
-	return row*t.boardsize + int(col-'A')
+	return row*t.width + int(col-'A')
 }
 
 func (t *HexTracker) String() (s string) {
 	s += "   "
-	for col := 0; col < t.boardsize; col++ {
+	for col := 0; col < t.width; col++ {
 		alpha := col + 'A'
 		if alpha >= 'I' {
 			alpha++
 		}
 		s += string(alpha)
-		if col != t.boardsize-1 {
+		if col != t.width-1 {
 			s += " "
 		}
 	}
 	s += "\n"
-	for row := 0; row < t.boardsize; row++ {
+	for row := 0; row < t.height; row++ {
 		for i := 0; i < row; i++ {
 			s += " "
 		}
 		s += fmt.Sprintf("%2.d ", row+1)
-		for col := 0; col < t.boardsize; col++ {
-			v := row*t.boardsize + col
+		for col := 0; col < t.width; col++ {
+			v := row*t.width + col
 			s += Ctoa(t.board[v])
-			if col != t.boardsize-1 {
+			if col != t.width-1 {
 				s += " "
 			}
 		}
 		s += fmt.Sprintf(" %2.d", row+1)
-		if row != t.boardsize-1 {
+		if row != t.height-1 {
 			s += "\n"
 		}
 	}
 	s += "\n  "
 
-	for i := 0; i < t.boardsize; i++ {
+	for i := 0; i < t.height; i++ {
 		s += " "
 	}
-	for col := 0; col < t.boardsize; col++ {
+	for col := 0; col < t.width; col++ {
 		alpha := col + 'A'
 		if alpha >= 'I' {
 			alpha++
 		}
 		s += string(alpha)
-		if col != t.boardsize-1 {
+		if col != t.width-1 {
 			s += " "
 		}
 	}
@@ -384,6 +456,7 @@ func (t *HexTracker) logProbabilities() {
 	}
 }
 
+// geometry tables keyed by board_key, built by hex_geometry the first time a size is used
 var hex_adj map[int][]int
 var hex_neighbors map[int][][][]int
 var hex_min_hash map[uint32]uint32
@@ -392,10 +465,6 @@ var hex_hash_mask [7][4]uint32
 func init() {
 	hex_adj = make(map[int][]int)
 	hex_neighbors = make(map[int][][][]int)
-	for boardsize := 3; boardsize <= 19; boardsize++ {
-		setup_hex_adj(boardsize)
-		setup_hex_neighbors(boardsize)
-	}
 	hex_hash_mask = [7][4]uint32{
 		[4]uint32{
 			0x00000000,
@@ -443,9 +512,23 @@ func init() {
 	setup_hex_min_hash()
 }
 
-func setup_hex_adj(boardsize int) {
-	s := boardsize * boardsize
-	hex_adj[boardsize] = make([]int, s*6)
+// adjacent cells and sides, and pattern neighbors, of a width x height board
+func hex_geometry(width, height int) ([]int, [][][]int) {
+	geometry_lock.Lock()
+	defer geometry_lock.Unlock()
+	key := board_key(width, height)
+	if _, ok := hex_adj[key]; !ok {
+		setup_hex_adj(width, height)
+		setup_hex_neighbors(width, height)
+	}
+	return hex_adj[key], hex_neighbors[key]
+}
+
+func setup_hex_adj(width, height int) {
+	key := board_key(width, height)
+	s := width * height
+	hex_adj[key] = make([]int, s*6)
+	adj := hex_adj[key]
 
 	SIDE_UP = s
 	SIDE_DOWN = s + 1
@@ -453,90 +536,92 @@ func setup_hex_adj(boardsize int) {
 	SIDE_RIGHT = s + 3
 
 	for i := 0; i < s; i++ {
-		hex_adj[boardsize][i*6+UP] = -1
-		hex_adj[boardsize][i*6+DOWN] = -1
-		hex_adj[boardsize][i*6+UP_RIGHT] = -1
-		hex_adj[boardsize][i*6+DOWN_RIGHT] = -1
-		hex_adj[boardsize][i*6+UP_LEFT] = -1
-		hex_adj[boardsize][i*6+DOWN_LEFT] = -1
+		adj[i*6+UP] = -1
+		adj[i*6+DOWN] = -1
+		adj[i*6+UP_RIGHT] = -1
+		adj[i*6+DOWN_RIGHT] = -1
+		adj[i*6+UP_LEFT] = -1
+		adj[i*6+DOWN_LEFT] = -1
 	}
 
 	for i := 0; i < s; i++ {
-		hex_adj[boardsize][i*6+UP_LEFT] = i - boardsize
-		hex_adj[boardsize][i*6+UP_RIGHT] = hex_adj[boardsize][i*6+UP_LEFT] + 1
-		hex_adj[boardsize][i*6+DOWN_RIGHT] = i + boardsize
-		hex_adj[boardsize][i*6+DOWN_LEFT] = hex_adj[boardsize][i*6+DOWN_RIGHT] - 1
-		hex_adj[boardsize][i*6+LEFT] = i - 1
-		hex_adj[boardsize][i*6+RIGHT] = i + 1
-		if i < boardsize {
-			hex_adj[boardsize][i*6+UP_LEFT] = SIDE_UP
-			hex_adj[boardsize][i*6+UP_RIGHT] = SIDE_UP
-		}
-		if i > s-boardsize-1 {
-			hex_adj[boardsize][i*6+DOWN_LEFT] = SIDE_DOWN
-			hex_adj[boardsize][i*6+DOWN_RIGHT] = SIDE_DOWN
-		}
-		if i%boardsize == 0 {
-			hex_adj[boardsize][i*6+LEFT] = SIDE_LEFT
-			hex_adj[boardsize][i*6+DOWN_LEFT] = SIDE_LEFT
-		}
-		if (i+1)%boardsize == 0 {
-			hex_adj[boardsize][i*6+RIGHT] = SIDE_RIGHT
-			hex_adj[boardsize][i*6+UP_RIGHT] = SIDE_RIGHT
+		adj[i*6+UP_LEFT] = i - width
+		adj[i*6+UP_RIGHT] = adj[i*6+UP_LEFT] + 1
+		adj[i*6+DOWN_RIGHT] = i + width
+		adj[i*6+DOWN_LEFT] = adj[i*6+DOWN_RIGHT] - 1
+		adj[i*6+LEFT] = i - 1
+		adj[i*6+RIGHT] = i + 1
+		if i < width {
+			adj[i*6+UP_LEFT] = SIDE_UP
+			adj[i*6+UP_RIGHT] = SIDE_UP
+		}
+		if i > s-width-1 {
+			adj[i*6+DOWN_LEFT] = SIDE_DOWN
+			adj[i*6+DOWN_RIGHT] = SIDE_DOWN
+		}
+		if i%width == 0 {
+			adj[i*6+LEFT] = SIDE_LEFT
+			adj[i*6+DOWN_LEFT] = SIDE_LEFT
+		}
+		if (i+1)%width == 0 {
+			adj[i*6+RIGHT] = SIDE_RIGHT
+			adj[i*6+UP_RIGHT] = SIDE_RIGHT
 		}
 	}
 }
 
-func setup_hex_neighbors(size int) {
-	hex_neighbors[size] = make([][][]int, 2)
-	hex_neighbors[size][0] = make([][]int, size*size)
-	hex_neighbors[size][1] = make([][]int, size*size)
+func setup_hex_neighbors(width, height int) {
+	key := board_key(width, height)
+	size := width * height
+	hex_neighbors[key] = make([][][]int, 2)
+	hex_neighbors[key][0] = make([][]int, size)
+	hex_neighbors[key][1] = make([][]int, size)
 
-	neighbors := make([][]int, size*size)
-	for vertex := 0; vertex < size*size; vertex++ {
+	neighbors := make([][]int, size)
+	for vertex := 0; vertex < size; vertex++ {
 		v2 := vertex + 1
-		v3 := vertex + size
-		v4 := vertex + size + 1
-		if (vertex+1)%size == 0 {
+		v3 := vertex + width
+		v4 := vertex + width + 1
+		if (vertex+1)%width == 0 {
 			v2 = -1
 			v4 = -1
 		}
-		if vertex >= (size*size)-size {
+		if vertex >= size-width {
 			v3 = -1
 			v4 = -1
 		}
-		hex_neighbors[size][0][vertex] = []int{vertex, v2, v3, v4}
+		hex_neighbors[key][0][vertex] = []int{vertex, v2, v3, v4}
 
 		neighbors[vertex] = make([]int, 7)
-		neighbors[vertex][0] = vertex - size
-		neighbors[vertex][1] = vertex - size + 1
+		neighbors[vertex][0] = vertex - width
+		neighbors[vertex][1] = vertex - width + 1
 		neighbors[vertex][2] = vertex + 1
-		neighbors[vertex][3] = vertex + size
-		neighbors[vertex][4] = vertex + size - 1
+		neighbors[vertex][3] = vertex + width
+		neighbors[vertex][4] = vertex + width - 1
 		neighbors[vertex][5] = vertex - 1
 		neighbors[vertex][6] = vertex
-		if vertex%size == 0 {
+		if vertex%width == 0 {
 			// left
 			neighbors[vertex][4] = -1
 			neighbors[vertex][5] = -1
 		}
-		if (vertex+1)%size == 0 {
+		if (vertex+1)%width == 0 {
 			// right
 			neighbors[vertex][1] = -1
 			neighbors[vertex][2] = -1
 		}
-		if vertex < size {
+		if vertex < width {
 			// top
 			neighbors[vertex][0] = -1
 			neighbors[vertex][1] = -1
 		}
-		if vertex >= (size*size)-size {
+		if vertex >= size-width {
 			// bottom
 			neighbors[vertex][3] = -1
 			neighbors[vertex][4] = -1
 		}
 	}
-	hex_neighbors[size][1] = neighbors
+	hex_neighbors[key][1] = neighbors
 }
 
 func setup_hex_min_hash() {
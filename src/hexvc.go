@@ -0,0 +1,319 @@
+package main
+
+import (
+	"container/vector"
+	"fmt"
+	"strings"
+)
+
+// connections kept for each pair of points, a new one is dropped when the list is full
+const (
+	HEX_MAX_VCS = 4
+	HEX_MAX_SCS = 8
+)
+
+/*
+	Virtual connections of one color in a Hex position, found with H-search
+	points are the empty cells and color's groups, a group touching one of color's
+	sides is that side
+	a VC (virtual connection) holds even if the opponent moves first, an SC (semi
+	connection) only if color moves first, by playing its key
+	both need every empty cell of their carrier
+	adjacent points are connected with an empty carrier, then
+	AND: VCs x-u and u-y with disjoint carriers, neither holding x or y, give a VC
+	x-y if u is a group and an SC x-y with key u if u is empty
+	OR: SCs x-y whose carriers have no cell in common give a VC x-y
+	bridges and the edge templates (II, and the ziggurat IIIa) come out of these rules
+*/
+type HexVCs struct {
+	t     *HexTracker
+	color byte
+	words int
+	vcs   map[int][]*Connection
+	scs   map[int][]*Connection
+	// points with at least one VC to each point
+	links [][]int
+	queue *vector.Vector
+}
+
+// a VC or SC between two points, Key is -1 for a VC
+type Connection struct {
+	From, To int
+	Key      int
+	Carrier  Carrier
+}
+
+// a set of cells as a bitset
+type Carrier []uint64
+
+func (c Carrier) Has(v int) bool {
+	return v < len(c)*64 && c[v>>6]&(1<<uint(v&63)) != 0
+}
+
+func (c Carrier) With(v int) Carrier {
+	d := make(Carrier, len(c))
+	copy(d, c)
+	d[v>>6] |= 1 << uint(v&63)
+	return d
+}
+
+func (c Carrier) Or(d Carrier) Carrier {
+	e := make(Carrier, len(c))
+	for i := range c {
+		e[i] = c[i] | d[i]
+	}
+	return e
+}
+
+func (c Carrier) And(d Carrier) Carrier {
+	e := make(Carrier, len(c))
+	for i := range c {
+		e[i] = c[i] & d[i]
+	}
+	return e
+}
+
+func (c Carrier) Intersects(d Carrier) bool {
+	for i := range c {
+		if c[i]&d[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Carrier) Subset(d Carrier) bool {
+	for i := range c {
+		if c[i]&^d[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Carrier) Len() (n int) {
+	for _, u := range c {
+		for ; u != 0; n++ {
+			u &= u - 1
+		}
+	}
+	return
+}
+
+func (c Carrier) Cells() (cells []int) {
+	for i, u := range c {
+		for j := 0; u != 0; j++ {
+			if u&1 != 0 {
+				cells = append(cells, i*64+j)
+			}
+			u >>= 1
+		}
+	}
+	return
+}
+
+func (t *HexTracker) VirtualConnections(color byte) *HexVCs {
+	s := new(HexVCs)
+	s.t = t
+	s.color = color
+	s.words = (t.sqsize + 63) / 64
+	s.vcs = make(map[int][]*Connection)
+	s.scs = make(map[int][]*Connection)
+	s.links = make([][]int, t.sqsize+4)
+	s.queue = new(vector.Vector)
+	for v := 0; v < t.sqsize; v++ {
+		x := s.point(v)
+		if x == -1 {
+			continue
+		}
+		for i := 0; i < 6; i++ {
+			if y := s.point(t.adj[v*6+i]); y != -1 && y != x {
+				s.add(x, y, -1, make(Carrier, s.words))
+			}
+		}
+	}
+	// new VCs are queued in the order they are found, so small carriers come first
+	for i := 0; i < s.queue.Len(); i++ {
+		c := s.queue.At(i).(*Connection)
+		s.and(c.From, c.To, c)
+		s.and(c.To, c.From, c)
+	}
+	return s
+}
+
+// point of a vertex or side, -1 for the opponent's stones and sides
+func (s *HexVCs) point(v int) int {
+	if s.empty(v) {
+		return v
+	}
+	if s.t.owns(s.color, v) {
+		return find(v, s.t.parent)
+	}
+	return -1
+}
+
+func (s *HexVCs) empty(p int) bool {
+	return p >= 0 && p < s.t.sqsize && s.t.board[p] == EMPTY
+}
+
+func (s *HexVCs) key(x, y int) int {
+	if x > y {
+		x, y = y, x
+	}
+	return x*(s.t.sqsize+4) + y
+}
+
+// AND rule: the VC c between end and mid with every VC between mid and another point
+func (s *HexVCs) and(end, mid int, c *Connection) {
+	for _, y := range s.links[mid] {
+		if y == end {
+			continue
+		}
+		for _, d := range s.vcs[s.key(mid, y)] {
+			if c.Carrier.Intersects(d.Carrier) || c.Carrier.Has(y) || d.Carrier.Has(end) {
+				continue
+			}
+			if s.empty(mid) {
+				s.add(end, y, mid, c.Carrier.Or(d.Carrier).With(mid))
+			} else {
+				s.add(end, y, -1, c.Carrier.Or(d.Carrier))
+			}
+		}
+	}
+}
+
+// OR rule: the SCs between x and y, from c on, are combined while they shrink the cells all of them need
+func (s *HexVCs) or(x, y int, c *Connection) {
+	common, carrier := c.Carrier, c.Carrier
+	for _, d := range s.scs[s.key(x, y)] {
+		if shrunk := common.And(d.Carrier); d != c && shrunk.Len() < common.Len() {
+			common, carrier = shrunk, carrier.Or(d.Carrier)
+			if common.Len() == 0 {
+				s.add(x, y, -1, carrier)
+				return
+			}
+		}
+	}
+}
+
+// add a VC (key -1) or SC between x and y, unless one with a smaller carrier is known
+func (s *HexVCs) add(x, y, key int, carrier Carrier) {
+	k := s.key(x, y)
+	for _, c := range s.vcs[k] {
+		if c.Carrier.Subset(carrier) {
+			return
+		}
+	}
+	c := &Connection{x, y, key, carrier}
+	if key == -1 {
+		if len(s.vcs[k]) == 0 {
+			s.links[x] = append(s.links[x], y)
+			s.links[y] = append(s.links[y], x)
+		}
+		var ok bool
+		if s.vcs[k], ok = insert_connection(s.vcs[k], c, HEX_MAX_VCS); ok {
+			s.queue.Push(c)
+		}
+		return
+	}
+	for _, d := range s.scs[k] {
+		if d.Carrier.Subset(carrier) {
+			return
+		}
+	}
+	var ok bool
+	if s.scs[k], ok = insert_connection(s.scs[k], c, HEX_MAX_SCS); ok {
+		s.or(x, y, c)
+	}
+}
+
+// drop the connections c makes redundant, then add c if there is room
+func insert_connection(list []*Connection, c *Connection, max int) ([]*Connection, bool) {
+	kept := list[0:0]
+	for _, d := range list {
+		if !c.Carrier.Subset(d.Carrier) {
+			kept = append(kept, d)
+		}
+	}
+	if len(kept) >= max {
+		return kept, false
+	}
+	return append(kept, c), true
+}
+
+// the VC between the points of vertices or sides a and b with the smallest carrier, nil if there is none
+func (s *HexVCs) Connected(a, b int) *Connection {
+	x, y := s.point(a), s.point(b)
+	if x == -1 || y == -1 {
+		return nil
+	}
+	if x == y {
+		return &Connection{x, y, -1, make(Carrier, s.words)}
+	}
+	var best *Connection
+	for _, c := range s.vcs[s.key(x, y)] {
+		if best == nil || c.Carrier.Len() < best.Carrier.Len() {
+			best = c
+		}
+	}
+	return best
+}
+
+// the SCs between the points of vertices or sides a and b
+func (s *HexVCs) SemiConnections(a, b int) []*Connection {
+	x, y := s.point(a), s.point(b)
+	if x == -1 || y == -1 || x == y {
+		return nil
+	}
+	return s.scs[s.key(x, y)]
+}
+
+// VCs between two groups, the one with the smallest carrier for each pair
+func (s *HexVCs) Groups() (groups []*Connection) {
+	for x := range s.links {
+		if s.empty(x) {
+			continue
+		}
+		for _, y := range s.links[x] {
+			if y > x && !s.empty(y) {
+				groups = append(groups, s.Connected(x, y))
+			}
+		}
+	}
+	return
+}
+
+/*
+	GoGui gfx of the VCs between groups of both colors: cells in black carriers are
+	blue, in white carriers red, in both magenta
+*/
+func VCGfx(t *HexTracker) string {
+	in := make([]byte, t.sqsize)
+	count := make(map[byte]int)
+	for _, color := range []byte{BLACK, WHITE} {
+		for _, c := range t.VirtualConnections(color).Groups() {
+			count[color]++
+			for _, v := range c.Carrier.Cells() {
+				in[v] |= color
+			}
+		}
+	}
+	cells := make(map[byte][]string)
+	for v := range in {
+		switch in[v] {
+		case BLACK, WHITE:
+			cells[in[v]] = append(cells[in[v]], t.Vtoa(v))
+		case BLACK | WHITE:
+			cells[BOTH] = append(cells[BOTH], t.Vtoa(v))
+		}
+	}
+	s := ""
+	names := map[byte]string{BLACK: "blue", WHITE: "red", BOTH: "magenta"}
+	for _, color := range []byte{BLACK, WHITE, BOTH} {
+		if len(cells[color]) > 0 {
+			s += fmt.Sprintf("COLOR %s %s\n", names[color], strings.Join(cells[color], " "))
+		}
+	}
+	s += fmt.Sprintf("TEXT %d black and %d white virtual connections", count[BLACK], count[WHITE])
+	return s
+}
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/ajstarks/svgo"
+	"io/ioutil"
+	"json"
 	"log"
+	"math"
+	"net"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 var config *Config
@@ -86,3 +94,902 @@ func TestWeightTree(t *testing.T) {
 	}
 	fmt.Println(tree.Prob(BLACK, target), float64(count)/float64(samples))
 }
+
+func testTransport(t *testing.T, transport Transport) {
+	messages, err := transport.Subscribe("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.Publish("test", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-messages; string(msg) != "hello" {
+		t.Errorf("published %q, received %q", "hello", msg)
+	}
+	if err := transport.Unsubscribe("test", messages); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg, ok := <-messages:
+		if ok {
+			t.Errorf("received %q after unsubscribing", msg)
+		}
+	case <-time.After(1e9):
+		t.Errorf("subscription was not closed")
+	}
+	transport.Push("queue", []byte("1"))
+	transport.Push("queue", []byte("2"))
+	for _, expected := range []string{"1", "2"} {
+		if msg, err := transport.Pop("queue", 1e9); err != nil || string(msg) != expected {
+			t.Errorf("expected to pop %q, got %q (%v)", expected, msg, err)
+		}
+	}
+	if msg, _ := transport.Pop("queue", 1e7); msg != nil {
+		t.Errorf("expected empty queue, got %q", msg)
+	}
+}
+
+func TestMemTransport(t *testing.T) {
+	transport := NewMemTransport()
+	testTransport(t, transport)
+	// a subscriber that never reads must not block publishing
+	transport.Subscribe("slow")
+	for i := 0; i < 1100; i++ {
+		transport.Publish("slow", []byte("ignored"))
+	}
+}
+
+func TestTCPTransport(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go ServeBroker(l)
+	testTransport(t, NewTCPTransport(l.Addr().String()))
+}
+
+func TestClusterEval(t *testing.T) {
+	log.Println("Cluster Eval")
+	config.Go = true
+	config.Hex = false
+	config.MaxPlayouts = 1000
+	transport := NewMemTransport()
+	shutdown := make(chan bool, 1)
+	InitCluster(config, transport, shutdown)
+	results, _ := transport.Subscribe("results")
+	job := *config
+	job.MsgType = "eval"
+	job.JobId = "test"
+	job.Moves = []int{40}
+	bytes, err := json.Marshal(&job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport.Push("jobs", bytes)
+	var result Result
+	if err := json.Unmarshal(<-results, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.JobId != "test" || result.Error != "" || result.Best == -1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	transport.Publish("commands", []byte("shutdown"))
+	<-shutdown
+}
+
+func TestWorkerDrain(t *testing.T) {
+	log.Println("Worker Drain")
+	transport := NewMemTransport()
+	heartbeats, _ := transport.Subscribe("workers")
+	w := &Worker{id: "test", capacity: 1, sem: make(chan int, 1), transport: transport, config: config}
+	// the only slot is busy, a job and then a shutdown arrive
+	w.sem <- 1
+	commands, shutdown := make(chan []byte, 2), make(chan bool, 1)
+	commands <- []byte(`{"MsgType":"search"}`)
+	commands <- []byte("shutdown")
+	go w.listen(commands, shutdown)
+	hb := new(Heartbeat)
+	select {
+	case msg := <-heartbeats:
+		json.Unmarshal(msg, hb)
+	case <-time.After(1e9):
+	}
+	if hb.State != "draining" {
+		t.Fatalf("expected to start draining while the slot is busy, got %q", hb.State)
+	}
+	// the busy job finishes, the queued job is dropped and the worker goes down
+	<-w.sem
+	select {
+	case <-shutdown:
+	case <-time.After(1e9):
+		t.Fatal("worker did not shut down")
+	}
+	if json.Unmarshal(<-heartbeats, hb); hb.State != "down" || hb.Running != 0 {
+		t.Errorf("expected to go down with no jobs running, got %q with %d", hb.State, hb.Running)
+	}
+}
+
+func TestBookSymmetry(t *testing.T) {
+	log.Println("Book Symmetry")
+	config.Go = true
+	config.Hex = false
+	book := NewBook(config)
+	for _, s := range book.symmetries() {
+		for v := 0; v < config.Size*config.Size; v++ {
+			if book.inverse(s, book.transform(s, v)) != v {
+				t.Fatalf("symmetry %d does not invert at %d", s, v)
+			}
+		}
+	}
+	// C9 and its mirror images G9, A7 and J3
+	var hash BookHash
+	for i, vertex := range []string{"C9", "G9", "A7", "J3"} {
+		tracker := NewTracker(config)
+		tracker.Play(BLACK, tracker.Atov(vertex))
+		h, _ := book.Hash(tracker, WHITE)
+		if i > 0 && h != hash {
+			t.Errorf("%s hashes differently from C9", vertex)
+		}
+		hash = h
+	}
+}
+
+func TestHexBookSymmetry(t *testing.T) {
+	log.Println("Hex Book Symmetry")
+	config.Go = false
+	config.Hex = true
+	book := NewBook(config)
+	// C2 and its 180 degree rotation G8 are equivalent, its mirror image G2 is not
+	hashes := make(map[string]BookHash)
+	for _, vertex := range []string{"C2", "G8", "G2"} {
+		tracker := NewTracker(config)
+		tracker.Play(BLACK, tracker.Atov(vertex))
+		hashes[vertex], _ = book.Hash(tracker, WHITE)
+	}
+	if hashes["C2"] != hashes["G8"] {
+		t.Errorf("G8 hashes differently from C2")
+	}
+	if hashes["C2"] == hashes["G2"] {
+		t.Errorf("G2 hashes the same as C2")
+	}
+	// a book move stored for C2 is played rotated for G8
+	tracker := NewTracker(config)
+	tracker.Play(BLACK, tracker.Atov("C2"))
+	hash, sym := book.Hash(tracker, WHITE)
+	book.Positions[hash] = &BookPosition{Color: WHITE, Children: []*BookMove{&BookMove{Vertex: book.transform(sym, tracker.Atov("D3")), Visits: 1000}}}
+	tracker = NewTracker(config)
+	tracker.Play(BLACK, tracker.Atov("G8"))
+	if vertex, ok := book.Move(tracker, WHITE); !ok || tracker.Vtoa(vertex) != "F7" {
+		t.Errorf("expected book move F7, got %s", tracker.Vtoa(vertex))
+	}
+}
+
+func TestBookImport(t *testing.T) {
+	log.Println("Book Import")
+	config.Go = true
+	config.Hex = false
+	trees, err := ParseSGF("(;GM[1]SZ[9]KM[6.5]RE[B+R];B[ee];W[ce](;B[gc])(;B[cg]))\n(;SZ[9]KM[6.5]RE[W+3.5];B[ee];W[eg])")
+	if err != nil || len(trees) != 2 {
+		t.Fatalf("parsed %d trees: %v", len(trees), err)
+	}
+	book := NewBook(config)
+	for _, tree := range trees {
+		if err := book.importGame(tree.MainLine()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tracker := NewTracker(config)
+	moves := book.Candidates(tracker, BLACK)
+	if len(moves) != 1 || tracker.Vtoa(moves[0].Vertex) != "E5" || moves[0].Visits != 2 || moves[0].Wins != 1 {
+		t.Errorf("unexpected book moves %v", moves)
+	}
+	// W[ce] and W[eg] are the same move under symmetry
+	tracker.Play(BLACK, tracker.Atov("E5"))
+	if moves = book.Candidates(tracker, WHITE); len(moves) != 1 || moves[0].Visits != 2 {
+		t.Errorf("unexpected book moves %v", moves)
+	}
+	// a book read with other hashes finds its positions again once rehashed
+	positions := make(map[BookHash]*BookPosition)
+	for hash, pos := range book.Positions {
+		positions[hash>>32] = pos
+	}
+	book.Positions = positions
+	book.rehash()
+	if moves = book.Candidates(tracker, WHITE); len(moves) != 1 || moves[0].Visits != 2 || !moves[0].Expand {
+		t.Errorf("unexpected book moves after rehashing %v", moves)
+	}
+	if _, ok := book.Positions[moves[0].Next]; !ok {
+		t.Errorf("the position after a book move was not rehashed")
+	}
+	// flat MC never visits the children, which must not put NaN in the book
+	config.TreeSearch, config.MaxPlayouts = false, 100
+	defer func() { config.TreeSearch, config.MaxPlayouts = true, 10000 }()
+	book = NewBook(config)
+	pos := book.search(nil)
+	for _, move := range pos.Children {
+		if move.Visits == 0 {
+			t.Errorf("unvisited move %d in the book", move.Vertex)
+		}
+	}
+	if hash, _ := book.Hash(NewTracker(config), BLACK); math.IsNaN(book.value(hash, make(map[BookHash]float64))) {
+		t.Errorf("the empty board has no value")
+	}
+}
+
+func TestGomoku(t *testing.T) {
+	log.Println("Gomoku")
+	config.Go = false
+	config.Hex = false
+	config.Gomoku = true
+	defer func() { config.Gomoku = false }()
+	config.Size = 15
+	defer func() { config.Size = 9 }()
+	config.GomokuRule = "standard"
+	tracker := NewTracker(config)
+	for _, vertex := range []string{"C8", "D8", "E8", "F8"} {
+		tracker.Play(BLACK, tracker.Atov(vertex))
+	}
+	tracker.Play(BLACK, tracker.Atov("H8"))
+	// G8 would make six, which doesn't win under standard rules
+	cp := tracker.Copy()
+	cp.Play(BLACK, cp.Atov("G8"))
+	if cp.Winner() != EMPTY {
+		t.Errorf("overline won under standard rules")
+	}
+	tracker.Play(WHITE, tracker.Atov("G8"))
+	tracker.Play(BLACK, tracker.Atov("B8"))
+	if tracker.Winner() != BLACK {
+		t.Errorf("five did not win")
+	}
+	config.GomokuRule = "renju"
+	tracker = NewTracker(config)
+	for _, vertex := range []string{"G7", "H7", "F8", "F9"} {
+		tracker.Play(BLACK, tracker.Atov(vertex))
+	}
+	// F7 makes two open threes
+	if tracker.Legal(BLACK, tracker.Atov("F7")) || !tracker.Legal(WHITE, tracker.Atov("F7")) {
+		t.Errorf("double three should only be forbidden for black")
+	}
+	// E8 makes B.BBB.B, two fours on one line
+	tracker = NewTracker(config)
+	for _, vertex := range []string{"B8", "D8", "F8"} {
+		tracker.Play(BLACK, tracker.Atov(vertex))
+	}
+	// legal before H8, which must not be remembered once H8 is played
+	if !tracker.Legal(BLACK, tracker.Atov("E8")) {
+		t.Errorf("a single four should be allowed")
+	}
+	tracker.Play(BLACK, tracker.Atov("H8"))
+	if tracker.Legal(BLACK, tracker.Atov("E8")) {
+		t.Errorf("double four on one line should be forbidden")
+	}
+	// H8 makes two threes, but the only straight four of the one along row 8 is at G8,
+	// a double four with the G column, so it is not a real three
+	tracker = NewTracker(config)
+	for _, vertex := range []string{"J8", "K8", "G11", "G10", "G9", "H7", "H6"} {
+		tracker.Play(BLACK, tracker.Atov(vertex))
+	}
+	tracker.Play(WHITE, tracker.Atov("M8"))
+	if !tracker.Legal(BLACK, tracker.Atov("H8")) {
+		t.Errorf("a three that can only become a straight four by a forbidden move should not count")
+	}
+	// the open three D8 E8 F8 becomes a straight four at C8 or G8, and J8 K8 . . N8 has none
+	tracker = NewTracker(config)
+	for _, vertex := range []string{"D8", "E8", "F8", "J8", "K8", "N8"} {
+		tracker.Play(BLACK, tracker.Atov(vertex))
+	}
+	gomoku := tracker.(*GomokuTracker)
+	if vertex := tracker.Vtoa(gomoku.fourThreat(BLACK, tracker.Atov("E8"))); vertex != "C8" && vertex != "G8" {
+		t.Errorf("expected a straight four at C8 or G8, got %s", vertex)
+	}
+	if vertex := gomoku.fourThreat(BLACK, tracker.Atov("N8")); vertex != -1 {
+		t.Errorf("expected no straight four near N8, got %s", tracker.Vtoa(vertex))
+	}
+	config.GomokuRule = "freestyle"
+	config.MaxPlayouts = 10000
+	tracker = NewTracker(config)
+	for _, vertex := range []string{"H8", "H9", "H10", "H11"} {
+		tracker.Play(WHITE, tracker.Atov(vertex))
+	}
+	tracker.Play(BLACK, tracker.Atov("H12"))
+	root := NewRoot(BLACK, tracker, config)
+	genmove(root, tracker)
+	if vertex := tracker.Vtoa(root.Best().Vertex); vertex != "H7" {
+		t.Errorf("expected block at H7, got %s", vertex)
+	}
+}
+
+func TestHavannah(t *testing.T) {
+	log.Println("Havannah")
+	config.Go = false
+	config.Hex = false
+	config.Havannah = true
+	defer func() { config.Havannah = false }()
+	config.Size = 4
+	defer func() { config.Size = 9 }()
+	wins := map[string][]string{
+		"bridge": []string{"D1", "E1", "F1", "G1"},
+		"fork":   []string{"C2", "D2", "E1", "C3", "B4", "A5"},
+		"ring":   []string{"D3", "E3", "E4", "D5", "C5", "C4"},
+	}
+	for win, vertices := range wins {
+		tracker := NewTracker(config).(*HavannahTracker)
+		for i, vertex := range vertices {
+			if tracker.Winner() != EMPTY {
+				t.Errorf("%s won early at move %d", win, i)
+			}
+			tracker.Play(BLACK, tracker.Atov(vertex))
+			tracker.Verify()
+		}
+		if tracker.Winner() != BLACK || tracker.win != win {
+			t.Errorf("expected %s, got %s %s", win, Ctoa(tracker.Winner()), tracker.win)
+		}
+	}
+	config.MaxPlayouts = 1000
+	tracker := NewTracker(config)
+	root := NewRoot(BLACK, tracker, config)
+	genmove(root, tracker)
+}
+
+func TestY(t *testing.T) {
+	log.Println("Y")
+	config.Go = false
+	config.Hex = false
+	config.Y = true
+	defer func() { config.Y = false }()
+	config.Size = 5
+	defer func() { config.Size = 9 }()
+	wins := map[byte][]string{
+		BLACK: []string{"A1", "A2", "A3", "A4", "A5"},
+		WHITE: []string{"E1", "D1", "C1", "B1", "A1"},
+	}
+	for color, vertices := range wins {
+		tracker := NewTracker(config)
+		for i, vertex := range vertices {
+			if tracker.Winner() != EMPTY {
+				t.Errorf("%s won early at move %d", Ctoa(color), i)
+			}
+			tracker.Play(color, tracker.Atov(vertex))
+		}
+		if tracker.Winner() != color {
+			t.Errorf("expected %s to win, got %s", Ctoa(color), Ctoa(tracker.Winner()))
+		}
+	}
+	tracker := NewTracker(config)
+	if tracker.Legal(BLACK, tracker.Atov("E5")) {
+		t.Errorf("E5 is outside the triangle")
+	}
+	config.MaxPlayouts = 1000
+	root := NewRoot(BLACK, tracker, config)
+	genmove(root, tracker)
+}
+
+func TestOthello(t *testing.T) {
+	log.Println("Othello")
+	config.Go = false
+	config.Hex = false
+	config.Othello = true
+	defer func() { config.Othello = false }()
+	config.Size = 8
+	defer func() { config.Size = 9 }()
+	tracker := NewTracker(config)
+	legal := 0
+	for i := 0; i < tracker.Sqsize(); i++ {
+		if tracker.Legal(BLACK, i) {
+			legal++
+		}
+	}
+	if legal != 4 || tracker.Legal(BLACK, -1) {
+		t.Errorf("expected 4 opening moves and no pass, got %d", legal)
+	}
+	tracker.Play(BLACK, tracker.Atov("D3"))
+	tracker.Verify()
+	if bc, wc := tracker.Score(0); bc != 4 || wc != 1 {
+		t.Errorf("expected D4 to be flipped, score is %.0f to %.0f", bc, wc)
+	}
+	// passes are legal exactly when there is no other move, and the game ends when neither side can move
+	config.Size = 4
+	for game := 0; game < 100; game++ {
+		tracker := NewTracker(config).(*OthelloTracker)
+		color := BLACK
+		for tracker.Winner() == EMPTY {
+			moves := 0
+			for i := 0; i < tracker.Sqsize(); i++ {
+				if tracker.Legal(color, i) {
+					moves++
+				}
+			}
+			if (moves == 0) != tracker.Legal(color, -1) {
+				t.Fatalf("%d moves but pass legal is %t\n%s", moves, tracker.Legal(color, -1), tracker.String())
+			}
+			tracker.Play(color, tracker.random(color))
+			tracker.Verify()
+			color = Reverse(color)
+		}
+		bc, wc := tracker.Score(0)
+		if (bc > wc && tracker.Winner() != BLACK) || (wc > bc && tracker.Winner() != WHITE) || (bc == wc && tracker.Winner() != BOTH) {
+			t.Errorf("%s won %.0f to %.0f", Ctoa(tracker.Winner()), bc, wc)
+		}
+	}
+	config.Size = 6
+	config.MaxPlayouts = 1000
+	tracker = NewTracker(config)
+	root := NewRoot(BLACK, tracker, config)
+	genmove(root, tracker)
+	StatsBoard(root, tracker)
+}
+
+func TestRectangular(t *testing.T) {
+	log.Println("Rectangular")
+	config.Go = true
+	config.Hex = false
+	config.Width, config.Height = 7, 5
+	defer func() { config.Width, config.Height = 0, 0 }()
+	tracker := NewTracker(config)
+	if tracker.Width() != 7 || tracker.Height() != 5 || tracker.Sqsize() != 35 {
+		t.Errorf("expected a 7x5 board, got %dx%d", tracker.Width(), tracker.Height())
+	}
+	if tracker.Vtoa(0) != "A5" || tracker.Vtoa(34) != "G1" {
+		t.Errorf("expected A5 and G1, got %s and %s", tracker.Vtoa(0), tracker.Vtoa(34))
+	}
+	for v := 0; v < tracker.Sqsize(); v++ {
+		if tracker.Atov(tracker.Vtoa(v)) != v {
+			t.Errorf("%d converts to %s and back to %d", v, tracker.Vtoa(v), tracker.Atov(tracker.Vtoa(v)))
+		}
+	}
+	tracker.Playout(BLACK)
+	tracker.Verify()
+	config.MaxPlayouts = 1000
+	tracker = NewTracker(config)
+	root := NewRoot(BLACK, tracker, config)
+	genmove(root, tracker)
+	if rows := strings.Split(VisitsBoard(root, tracker), "\n"); len(rows) != 5 || len(strings.Fields(rows[0])) != 7 {
+		t.Errorf("expected 5 rows of 7 in the visits board")
+	}
+
+	width, height, err := SGFSize("7:5")
+	if err != nil || width != 7 || height != 5 {
+		t.Errorf("expected SZ[7:5] to be 7x5, got %dx%d", width, height)
+	}
+	if vertex, err := SGFVertex("gd", 7, 5); err != nil || vertex != 27 {
+		t.Errorf("expected gd to be 27, got %d", vertex)
+	}
+	if _, err := SGFVertex("ag", 7, 5); err == nil {
+		t.Errorf("ag is off a 7x5 board")
+	}
+
+	config.Go = false
+	config.Hex = true
+	config.Width, config.Height = 3, 5
+	tracker = NewTracker(config)
+	for i, vertex := range []string{"A1", "A2", "A3", "A4", "A5"} {
+		if tracker.Winner() != EMPTY {
+			t.Errorf("black won early at move %d", i)
+		}
+		tracker.Play(BLACK, tracker.Atov(vertex))
+	}
+	if tracker.Winner() != BLACK {
+		t.Errorf("expected black to connect top and bottom")
+	}
+}
+
+func TestLargeBoard(t *testing.T) {
+	log.Println("LargeBoard")
+	config.Go = true
+	config.Hex = false
+	config.Size = 25
+	config.Verify = true
+	defer func() { config.Size, config.Verify = 9, false }()
+	for i := 0; i < 10; i++ {
+		tracker := NewTracker(config)
+		if tracker.Vtoa(MAX_SIZE-1) != "Z25" {
+			t.Errorf("expected Z25, got %s", tracker.Vtoa(MAX_SIZE-1))
+		}
+		tracker.Playout(BLACK)
+		tracker.Verify()
+	}
+
+	config.Go = false
+	config.Hex = true
+	tracker := NewTracker(config)
+	tracker.Playout(BLACK)
+	if tracker.Winner() == EMPTY {
+		t.Errorf("expected a winner on a full 25x25 Hex board")
+	}
+}
+
+func TestHexVC(t *testing.T) {
+	log.Println("HexVC")
+	config.Go = false
+	config.Hex = true
+	config.Size = 5
+	config.PlayoutBridges = true
+	defer func() { config.Size, config.PlayoutBridges = 9, false }()
+	tracker := NewTracker(config).(*HexTracker)
+	tracker.Play(BLACK, tracker.Atov("B2"))
+	if c := tracker.VirtualConnections(BLACK).Connected(tracker.Atov("B2"), tracker.SIDE_UP); c == nil || c.Carrier.Len() != 2 ||
+		!c.Carrier.Has(tracker.Atov("B1")) || !c.Carrier.Has(tracker.Atov("C1")) {
+		t.Errorf("expected B2 to be connected to the top through B1 and C1")
+	}
+
+	config.Size = 7
+	tracker = NewTracker(config).(*HexTracker)
+	tracker.Play(BLACK, tracker.Atov("D3"))
+	if c := tracker.VirtualConnections(BLACK).Connected(tracker.Atov("D3"), tracker.SIDE_UP); c == nil || c.Carrier.Len() != 8 {
+		t.Errorf("expected the ziggurat to connect D3 to the top")
+	}
+	tracker.Play(BLACK, tracker.Atov("E4"))
+	vcs := tracker.VirtualConnections(BLACK)
+	if c := vcs.Connected(tracker.Atov("D3"), tracker.Atov("E4")); c == nil || c.Carrier.Len() != 2 ||
+		!c.Carrier.Has(tracker.Atov("E3")) || !c.Carrier.Has(tracker.Atov("D4")) {
+		t.Errorf("expected a bridge through E3 and D4")
+	}
+	if len(vcs.Groups()) == 0 {
+		t.Errorf("expected VCs between groups")
+	}
+	tracker.Play(WHITE, tracker.Atov("E3"))
+	if tracker.VirtualConnections(BLACK).Connected(tracker.Atov("D3"), tracker.Atov("E4")) != nil {
+		t.Errorf("the bridge was cut")
+	}
+	if save := tracker.save_bridge(BLACK, tracker.Atov("E3")); save != tracker.Atov("D4") {
+		t.Errorf("expected D4 to save the bridge, got %s", tracker.Vtoa(save))
+	}
+	tracker.Playout(BLACK)
+	if tracker.Winner() == EMPTY {
+		t.Errorf("expected a winner")
+	}
+}
+
+func TestHexInferior(t *testing.T) {
+	log.Println("HexInferior")
+	config.Go = false
+	config.Hex = true
+	config.Size = 5
+	defer func() { config.Size, config.Inferior = 9, false }()
+	tracker := NewTracker(config).(*HexTracker)
+	inferior := tracker.Inferior(BLACK)
+	for v := range inferior {
+		if (v == tracker.Atov("A1") || v == tracker.Atov("E5")) != (inferior[v] == HEX_DOMINATED) {
+			t.Errorf("expected only the acute corners to be dominated, %s is %d", tracker.Vtoa(v), inferior[v])
+		}
+	}
+
+	for _, vertex := range []string{"C2", "D2", "D3", "C4"} {
+		tracker.Play(BLACK, tracker.Atov(vertex))
+	}
+	expected := map[string]byte{
+		"C3": HEX_DEAD, "E2": HEX_DEAD,
+		"C1": HEX_CAPTURED_BLACK, "D1": HEX_CAPTURED_BLACK,
+		"B5": HEX_CAPTURED_BLACK, "C5": HEX_CAPTURED_BLACK,
+	}
+	inferior = tracker.Inferior(WHITE)
+	for vertex, kind := range expected {
+		if inferior[tracker.Atov(vertex)] != kind {
+			t.Errorf("expected %s to be %d, got %d", vertex, kind, inferior[tracker.Atov(vertex)])
+		}
+	}
+
+	config.Inferior = true
+	config.MaxPlayouts = 100
+	root := NewRoot(WHITE, tracker, config)
+	genmove(root, tracker)
+	for child := root.Child; child != nil; child = child.Sibling {
+		if inferior[child.Vertex] != 0 {
+			t.Errorf("%s is inferior but was searched", tracker.Vtoa(child.Vertex))
+		}
+	}
+	cp := tracker.Copy()
+	cp.Playout(WHITE)
+	if cp.Winner() == EMPTY {
+		t.Errorf("expected a winner")
+	}
+	if cp.Board()[tracker.Atov("C1")] != BLACK {
+		t.Errorf("expected the captured C1 to be filled by black")
+	}
+}
+
+func TestHexSolver(t *testing.T) {
+	log.Println("HexSolver")
+	config.Go = false
+	config.Hex = true
+	config.Size = 3
+	defer func() { config.Size, config.SolveVC = 9, false }()
+	// the winning openings, found by exhaustive search
+	wins := map[int][]string{
+		3: []string{"C1", "A2", "B2", "C2", "A3"},
+		4: []string{"D1", "C2", "B3", "A4"},
+	}
+	for _, vc := range []bool{false, true} {
+		config.SolveVC = vc
+		for size := 3; size <= 4; size++ {
+			if size == 4 && !vc {
+				continue
+			}
+			config.Size = size
+			tracker := NewTracker(config).(*HexTracker)
+			winner, vertex := NewHexSolver(config).Solve(tracker, BLACK)
+			if winner != BLACK || !strings.Contains(strings.Join(wins[size], " "), tracker.Vtoa(vertex)) {
+				t.Errorf("expected black to win %dx%d with one of %v, got %s %s", size, size, wins[size], Ctoa(winner), tracker.Vtoa(vertex))
+			}
+			tracker.Play(BLACK, 0)
+			if winner, _ := NewHexSolver(config).Solve(tracker, WHITE); winner != WHITE {
+				t.Errorf("expected white to win %dx%d after A1", size, size)
+			}
+		}
+	}
+	config.Size = 5
+	tracker := NewTracker(config).(*HexTracker)
+	if winner, vertex := NewHexSolver(config).Solve(tracker, BLACK); winner != BLACK || vertex == -1 {
+		t.Errorf("expected black to win 5x5")
+	}
+	// too large to solve in 1000 positions, the search gives up instead of running on
+	config.Size, config.SolveNodes = 11, 1000
+	defer func() { config.SolveNodes = 1000000 }()
+	tracker = NewTracker(config).(*HexTracker)
+	solver := NewHexSolver(config)
+	if winner, vertex := solver.Solve(tracker, BLACK); winner != EMPTY || vertex != -1 || solver.Nodes > 2000 {
+		t.Errorf("expected no answer on 11x11, got %s %s after %d positions", Ctoa(winner), tracker.Vtoa(vertex), solver.Nodes)
+	}
+}
+
+func TestTsumego(t *testing.T) {
+	log.Println("Tsumego")
+	config.Go = true
+	config.Hex = false
+	config.Size = 9
+	// white's straight three in the corner, C1 is the vital point for both
+	tracker := NewTracker(config).(*GoTracker)
+	for _, s := range []string{"A3", "B3", "C3", "D3", "E3", "F3", "F2", "F1"} {
+		tracker.Play(BLACK, tracker.Atov(s))
+	}
+	for _, s := range []string{"A1", "A2", "B2", "C2", "D2", "E2", "E1"} {
+		tracker.Play(WHITE, tracker.Atov(s))
+	}
+	expected := map[byte]string{BLACK: "dead C1", WHITE: "alive C1"}
+	for _, color := range []byte{BLACK, WHITE} {
+		for _, region := range []string{"A1:E1", ""} {
+			status, vertex, err := TsumegoStatus(tracker, color, "B2", region, config)
+			if err != nil || status+" "+tracker.Vtoa(vertex) != expected[color] {
+				t.Errorf("expected %s with %s to play in %q, got %s %s %v", expected[color], Ctoa(color), region, status, tracker.Vtoa(vertex), err)
+			}
+		}
+	}
+	// once white has two eyes black can't do anything
+	cp := tracker.Copy().(*GoTracker)
+	cp.Play(WHITE, cp.Atov("C1"))
+	if status, vertex, _ := TsumegoStatus(cp, BLACK, "A1", "", config); status != "alive" || vertex != -1 {
+		t.Errorf("expected white to live after C1, got %s %s", status, cp.Vtoa(vertex))
+	}
+	if _, _, err := TsumegoStatus(tracker, BLACK, "C1", "", config); err == nil {
+		t.Errorf("expected an error for a target with no stone")
+	}
+	if _, _, err := TsumegoStatus(tracker, BLACK, "B2", "A1:Z9", config); err == nil {
+		t.Errorf("expected an error for a region off the board")
+	}
+}
+
+func TestGoSeki(t *testing.T) {
+	log.Println("Go Seki")
+	config.Go = true
+	config.Hex = false
+	config.Width, config.Height = 5, 2
+	defer func() { config.Width, config.Height, config.Verify = 0, 0, false }()
+	// each side has an eye, A2 and E2, and they share C2, which neither can fill
+	tracker := NewTracker(config).(*GoTracker)
+	for _, s := range []string{"B2", "A1", "B1", "C1"} {
+		tracker.Play(BLACK, tracker.Atov(s))
+	}
+	for _, s := range []string{"D2", "D1", "E1"} {
+		tracker.Play(WHITE, tracker.Atov(s))
+	}
+	pointless := map[byte][]string{BLACK: []string{"A2", "C2"}, WHITE: []string{"C2", "E2"}}
+	for color, moves := range pointless {
+		for _, s := range moves {
+			if !tracker.pointless(color, tracker.Atov(s)) {
+				t.Errorf("expected %s %s to be pointless", Ctoa(color), s)
+			}
+		}
+	}
+	if bc, wc := tracker.Score(0); bc != 5 || wc != 4 {
+		t.Errorf("expected an area score of 5 to 4, got %.0f to %.0f", bc, wc)
+	}
+	config.Verify = true
+	tracker.SetKomi(0)
+	tracker.Playout(BLACK)
+	if tracker.Moves().Len() != 9 || tracker.Winner() != BLACK {
+		t.Errorf("expected both sides to pass and black to win the seki\n%s", tracker.String())
+	}
+	// playouts end with every move left pointless, which Verify checks
+	config.Width, config.Height = 9, 9
+	for i := 0; i < 20; i++ {
+		tracker := NewTracker(config).(*GoTracker)
+		tracker.Playout(BLACK)
+		if bc, wc := tracker.Score(0); tracker.Winner() != EMPTY && bc+wc > 81 {
+			t.Errorf("scored %.0f to %.0f on 81 points", bc, wc)
+		}
+	}
+}
+
+func TestPatternFile(t *testing.T) {
+	log.Println("Pattern File")
+	config.Go = true
+	config.Hex = false
+	// the empty neighborhood and those on the edge are spelled out too
+	boards := pattern_boards(config)
+	edge := []byte{ILLEGAL, ILLEGAL, ILLEGAL, BLACK, EMPTY, EMPTY, EMPTY, EMPTY, WHITE}
+	for _, a := range [][]byte{make([]byte, 9), edge} {
+		if _, exists := boards[pattern_hash(BLACK, a, config)]; !exists {
+			t.Errorf("missing pattern %v", pattern_grid(a, config))
+		}
+	}
+	// patterns the particle has no weight for are left out, so they are still initialized randomly after a round trip
+	p := NewParticle(nil, -1, 1)
+	black, white := pattern_hash(BLACK, edge, config), pattern_hash(WHITE, edge, config)
+	p.Position[black] = 0.5
+	ExportPatterns(p, "test_patterns.json", config)
+	defer os.Remove("test_patterns.json")
+	q := ImportPatterns("test_patterns.json", config).Best()
+	if _, exists := q.Position[white]; exists || q.Position[black] != 0.5 || len(q.Position) != 1 {
+		t.Errorf("expected only black %v to round trip, got %v", pattern_grid(edge, config), q.Position)
+	}
+	// the same weights are always written in the same order
+	for hash := range boards {
+		p.Position[hash] = 0.25
+	}
+	ExportPatterns(p, "test_patterns.json", config)
+	first, _ := ioutil.ReadFile("test_patterns.json")
+	ExportPatterns(p, "test_patterns.json", config)
+	if second, _ := ioutil.ReadFile("test_patterns.json"); !bytes.Equal(first, second) {
+		t.Errorf("exporting the same weights twice gave different files")
+	}
+	// Havannah hashes its neighborhoods differently, they would come back as go patterns
+	config.Go, config.Havannah = false, true
+	defer func() { config.Go, config.Havannah = true, false }()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("exported havannah patterns")
+		}
+	}()
+	ExportPatterns(p, "test_patterns.json", config)
+}
+
+func TestGoPatternReport(t *testing.T) {
+	log.Println("Go Pattern Report")
+	config.Go = true
+	config.Hex = false
+	// the expert eye patterns on the edge and in the corner are drawn with the edge marked
+	edges := 0
+	for _, pattern := range go_patterns(NewParticle(nil, -1, 1), config) {
+		if pattern.board[3] != ILLEGAL && pattern.board[1] != ILLEGAL && pattern.board[5] != ILLEGAL && pattern.board[7] != ILLEGAL {
+			continue
+		}
+		edges++
+		buffer := new(bytes.Buffer)
+		drawGoPattern(0, 0, 20, pattern.board, svg.New(buffer))
+		if !strings.Contains(buffer.String(), "stroke-width:4") {
+			t.Errorf("expected an edge marker for %v", pattern_grid(pattern.board, config))
+		}
+	}
+	if edges < 2 {
+		t.Errorf("expected the edge and corner eye patterns, got %d edge patterns", edges)
+	}
+}
+
+func TestBradleyTerry(t *testing.T) {
+	log.Println("Bradley-Terry")
+	// 3 wins to 1 puts the players an equal distance above and below 0
+	ratings := bradleyTerry([][]float64{{0, 3}, {1, 0}})
+	if math.Fabs(ratings[0]-72.89) > 0.1 || math.Fabs(ratings[1]+72.89) > 0.1 {
+		t.Errorf("expected ratings of about 72.89 and -72.89, got %v", ratings)
+	}
+	// even results rate everyone the same, whoever played how much
+	ratings = bradleyTerry([][]float64{{0, 2, 1}, {2, 0, 0}, {1, 0, 0}})
+	for i := range ratings {
+		if math.Fabs(ratings[i]) > 0.01 {
+			t.Errorf("expected even ratings, got %v", ratings)
+		}
+	}
+}
+
+func TestSPSAUpdate(t *testing.T) {
+	log.Println("SPSA Update")
+	tuner := &Tuner{Theta: []float64{0.5, 0, 0.3}}
+	// the second point was clamped at 0, the third is stuck between two equal points
+	plus, minus := []float64{0.6, 0.1, 0.3}, []float64{0.4, 0, 0.3}
+	tuner.update(0.01, 1, plus, minus)
+	for i, expected := range []float64{0.55, 0.1, 0.3} {
+		if math.Fabs(tuner.Theta[i]-expected) > 1e-9 {
+			t.Errorf("theta %d is %f, expected %f", i, tuner.Theta[i], expected)
+		}
+	}
+}
+
+func TestParticleJSON(t *testing.T) {
+	log.Println("Particle JSON")
+	// a particle sent to a worker initialises missing weights as the swarm would
+	s := new(Swarm)
+	p := NewParticle(s, 0, 100)
+	p.Get(1)
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := new(Particle)
+	if err := json.Unmarshal(data, q); err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range []uint32{1, 2, 1 << 31} {
+		if w := q.Get(hash); w != p.Get(hash) || w < 0 || w >= 100 {
+			t.Errorf("weight %d is %f on the worker and %f locally", hash, w, p.Get(hash))
+		}
+	}
+	// particles saved without a seed get different ones, or they would all fill in the same weights
+	old := []byte(`{"Strategy":0,"Position":{},"Min":0,"Max":1,"Fitness":0}`)
+	a, b := new(Particle), new(Particle)
+	json.Unmarshal(old, a)
+	json.Unmarshal(old, b)
+	if a.Seed == 0 || a.Seed == b.Seed {
+		t.Errorf("particles without a seed got seeds %d and %d", a.Seed, b.Seed)
+	}
+}
+
+func TestSGFGame(t *testing.T) {
+	log.Println("SGF Game")
+	defer func() { config.Go, config.Havannah, config.Size = true, false, 9 }()
+	f, err := os.Create("test_game.sgf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("test_game.sgf")
+	// Havannah has no GM number, a file without one is loaded as the game config plays
+	f.WriteString("(;FF[4]SZ[4];B[dd])")
+	f.Close()
+	config.Go, config.Hex, config.Havannah = false, false, true
+	tracker, _ := Load("test_game.sgf", config)
+	if _, ok := tracker.(*HavannahTracker); !ok {
+		t.Errorf("expected a Havannah game, got %s", GameName(config))
+	}
+	if SGFGame(config) != 0 {
+		t.Errorf("Havannah has no GM number, got %d", SGFGame(config))
+	}
+	// GM[13] is Neutron
+	f, _ = os.Create("test_game.sgf")
+	f.WriteString("(;FF[4]GM[13]SZ[5];B[aa])")
+	f.Close()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("GM[13] was loaded")
+		}
+	}()
+	Load("test_game.sgf", config)
+}
+
+func TestYBookSGF(t *testing.T) {
+	log.Println("Y Book SGF")
+	config.Go, config.Hex, config.Y = false, false, true
+	defer func() { config.Go, config.Y, config.Size = true, false, 9 }()
+	book := NewBook(config)
+	DumpBook(book, "test_book.sgf")
+	defer os.Remove("test_book.sgf")
+	// a dumped Y book can be read back with -sgf
+	tracker, _ := Load("test_book.sgf", config)
+	if _, ok := tracker.(*YTracker); !ok {
+		t.Errorf("expected a Y game, got %s", GameName(config))
+	}
+}
+
+func TestRestoreConfig(t *testing.T) {
+	log.Println("Restore Config")
+	saved, resumed := *config, *config
+	saved.Explore, saved.Generations, saved.Distributed, saved.ClusterAddr = 0.5, 10, false, ""
+	resumed.Explore, resumed.Generations, resumed.Distributed, resumed.ClusterAddr = 0.9, 20, true, "127.0.0.1:6380"
+	s := new(Swarm)
+	s.TrainConfig = &saved
+	s.restoreConfig(&resumed)
+	// the experiment comes from the checkpoint, how it is run from the command line
+	if resumed.Explore != 0.5 || resumed.Generations != 20 || !resumed.Distributed || resumed.ClusterAddr != "127.0.0.1:6380" {
+		t.Errorf("resumed with explore %.1f, %d generations, distributed %v at %q",
+			resumed.Explore, resumed.Generations, resumed.Distributed, resumed.ClusterAddr)
+	}
+}
@@ -2,14 +2,18 @@ package main
 
 import (
 	"container/vector"
+	"flag"
 	"fmt"
 	"github.com/ajstarks/svgo"
 	"gob"
+	"json"
 	"log"
 	"math"
 	"os"
+	"path/filepath"
 	"rand"
 	"sort"
+	"strconv"
 	"time"
 )
 
@@ -18,8 +22,24 @@ type Swarm struct {
 	Samples       uint
 	Generation    uint
 	Particles     Particles
+	Seed          int64
+	History       []GenerationStats
+	TrainConfig   *Config
 	config        *Config
 	evals         *vector.Vector
+	reference     *Particle
+	transport     Transport
+	results       <-chan []byte
+}
+
+// fitness statistics and timing of one generation, saved with each checkpoint
+type GenerationStats struct {
+	Generation           uint
+	Best, Mean, Min, Max float64
+	// winrate of the best particle against the reference, -1 if not measured
+	Strength float64
+	Started  int64
+	Seconds  float64
 }
 
 func NewSwarm(config *Config) *Swarm {
@@ -36,6 +56,11 @@ func NewSwarm(config *Config) *Swarm {
 	s.P = s.config.Parents
 	s.Samples = s.config.Samples
 	s.Generation = 0
+	s.Seed = config.RandSeed
+	if s.Seed == 0 {
+		s.Seed = time.Nanoseconds()
+	}
+	s.TrainConfig = config
 	s.Particles = make(Particles, s.Mu)
 	for i := uint(0); i < s.Mu; i++ {
 		s.Particles[i] = NewParticle(s, 0, 100)
@@ -48,7 +73,11 @@ type Particle struct {
 	Position map[uint32]float64
 	Min, Max float64
 	Fitness  float64
-	swarm    *Swarm
+	// missing weights are initialised from Seed, so they are the same wherever the particle plays
+	Seed  int64
+	swarm *Swarm
+	// decoded from a cluster job, missing weights are initialised as in the swarm
+	remote bool
 }
 
 func NewParticle(swarm *Swarm, min, max float64) *Particle {
@@ -56,6 +85,7 @@ func NewParticle(swarm *Swarm, min, max float64) *Particle {
 	p.swarm = swarm
 	p.Strategy = rand.Float64() * 0.05
 	p.Position = make(map[uint32]float64)
+	p.Seed = rand.Int63()
 	p.Min = min
 	p.Max = max
 	p.Fitness = 0
@@ -72,6 +102,7 @@ func (p *Particle) Copy() *Particle {
 	cp.Min = p.Min
 	cp.Max = p.Max
 	cp.Fitness = p.Fitness
+	cp.Seed = p.Seed
 	cp.swarm = p.swarm
 	return cp
 }
@@ -91,54 +122,268 @@ func (s Particles) Swap(i, j int) {
 }
 
 func (p *Particle) Get(i uint32) float64 {
-	if _, exists := p.Position[i]; !exists && p.swarm != nil {
+	if _, exists := p.Position[i]; !exists && (p.swarm != nil || p.remote) {
 		p.Init(i)
 	}
 	return p.Position[i]
 }
 
 func (p *Particle) Init(i uint32) {
-	p.Position[i] = p.Min + (p.Max-p.Min)*rand.Float64()
+	// splitmix64 of the seed and the hash, uniform in [0, 1)
+	z := uint64(p.Seed) + uint64(i)*0x9E3779B97F4A7C15
+	z = (z ^ z>>30) * 0xBF58476D1CE4E5B9
+	z = (z ^ z>>27) * 0x94D049BB133111EB
+	z ^= z >> 31
+	p.Position[i] = p.Min + (p.Max-p.Min)*float64(z>>11)/(1<<53)
+}
+
+// json only supports string map keys
+type particleJSON struct {
+	Strategy          float64
+	Position          map[string]float64
+	Min, Max, Fitness float64
+	Seed              int64
+}
+
+func (p *Particle) MarshalJSON() ([]byte, os.Error) {
+	pj := &particleJSON{p.Strategy, make(map[string]float64), p.Min, p.Max, p.Fitness, p.Seed}
+	for hash, weight := range p.Position {
+		pj.Position[strconv.Uitoa64(uint64(hash))] = weight
+	}
+	return json.Marshal(pj)
+}
+
+func (p *Particle) UnmarshalJSON(data []byte) os.Error {
+	pj := new(particleJSON)
+	if err := json.Unmarshal(data, pj); err != nil {
+		return err
+	}
+	p.Strategy, p.Min, p.Max, p.Fitness, p.Seed = pj.Strategy, pj.Min, pj.Max, pj.Fitness, pj.Seed
+	// written before particles had seeds
+	if p.Seed == 0 {
+		p.Seed = rand.Int63()
+	}
+	p.remote = true
+	p.Position = make(map[uint32]float64)
+	for key, weight := range pj.Position {
+		hash, err := strconv.Atoui64(key)
+		if err != nil {
+			return err
+		}
+		p.Position[uint32(hash)] = weight
+	}
+	return nil
 }
 
-func (s *Swarm) evalPlay(p1 *Particle, p2 *Particle) {
+// play one game between two particles, returning the winner
+// a nil particle plays without policy weights
+func (s *Swarm) playGame(black *Particle, white *Particle) byte {
 	config := new(Config)
 	*config = *s.config
-	for sample := uint(0); sample < s.Samples; sample++ {
-		log.Printf("game %d / %d\n", sample, s.Samples)
-		t := NewTracker(config)
-		color := BLACK
-		target := BLACK
-		if rand.Float64() < 0.5 {
-			target = WHITE
-		}
-		for {
-			if color == target {
-				config.policy_weights = p1
+	t := NewTracker(config)
+	color := BLACK
+	for {
+		if color == BLACK {
+			config.policy_weights = black
+		} else {
+			config.policy_weights = white
+		}
+		root := NewRoot(color, t, config)
+		genmove(root, t)
+		t.Play(color, root.Best().Vertex)
+		if config.Verbose {
+			log.Println(t.String())
+			log.Println(Ctoa(color), t.Vtoa(root.Best().Vertex))
+			log.Println(Ctoa(Reverse(color)), "to play")
+		}
+		if t.Winner() != EMPTY {
+			break
+		}
+		color = Reverse(color)
+	}
+	return t.Winner()
+}
+
+// a game to be played during evaluation
+type Match struct {
+	Black, White *Particle
+	Winner       byte
+	// set when a cluster worker finished the game, which may be unfinished (EMPTY)
+	played bool
+}
+
+// play all matches, on the cluster if training is distributed
+// any matches the cluster did not finish are played locally
+func (s *Swarm) play(matches []*Match) {
+	if s.config.Distributed {
+		s.playRemote(matches)
+	}
+	for i, m := range matches {
+		if !m.played {
+			log.Printf("game %d / %d\n", i, len(matches))
+			m.Winner = s.playGame(m.Black, m.White)
+			m.played = true
+		}
+	}
+}
+
+// true if p won the match
+func (m *Match) won(p *Particle) bool {
+	return (m.Winner == BLACK && m.Black == p) || (m.Winner == WHITE && m.White == p)
+}
+
+// play each particle against a random other particle Samples times, adjusting the fitness of both
+func (s *Swarm) evalPlay() {
+	matches := make([]*Match, 0, len(s.Particles)*int(s.Samples))
+	for i := range s.Particles {
+		p1 := s.Particles[i]
+		p2 := randParticle(s.Particles, []*Particle{p1})
+		for sample := uint(0); sample < s.Samples; sample++ {
+			if rand.Float64() < 0.5 {
+				matches = append(matches, &Match{Black: p1, White: p2})
 			} else {
-				config.policy_weights = p2
+				matches = append(matches, &Match{Black: p2, White: p1})
 			}
-			root := NewRoot(color, t, config)
-			genmove(root, t)
-			t.Play(color, root.Best().Vertex)
-			if config.Verbose {
-				log.Println(t.String())
-				log.Println(Ctoa(color), t.Vtoa(root.Best().Vertex))
-				log.Println(Ctoa(Reverse(color)), "to play")
+		}
+	}
+	s.play(matches)
+	for _, m := range matches {
+		switch m.Winner {
+		case BLACK:
+			m.Black.Fitness++
+			m.White.Fitness--
+		case WHITE:
+			m.White.Fitness++
+			m.Black.Fitness--
+		}
+	}
+}
+
+// games for each particle against the reference, alternating colors
+func (s *Swarm) referenceMatches(particles Particles, samples uint) []*Match {
+	matches := make([]*Match, 0, len(particles)*int(samples))
+	for _, p := range particles {
+		for sample := uint(0); sample < samples; sample++ {
+			if sample%2 == 0 {
+				matches = append(matches, &Match{Black: p, White: s.reference})
+			} else {
+				matches = append(matches, &Match{Black: s.reference, White: p})
 			}
-			if t.Winner() != EMPTY {
-				break
+		}
+	}
+	return matches
+}
+
+// play p against the reference particle, returns the fraction of games won by p
+func (s *Swarm) winrate(p *Particle, samples uint) float64 {
+	matches := s.referenceMatches(Particles{p}, samples)
+	s.play(matches)
+	wins := 0.0
+	for _, m := range matches {
+		if m.won(p) {
+			wins++
+		}
+	}
+	return wins / float64(samples)
+}
+
+// set the fitness of each particle to its winrate against the reference
+func (s *Swarm) evalReference() {
+	matches := s.referenceMatches(s.Particles, s.Samples)
+	s.play(matches)
+	for i, p := range s.Particles {
+		p.Fitness = 0
+		for _, m := range matches[i*int(s.Samples) : (i+1)*int(s.Samples)] {
+			if m.won(p) {
+				p.Fitness++
 			}
-			color = Reverse(color)
 		}
-		if t.Winner() == target {
-			p1.Fitness++
-			p2.Fitness--
-		} else {
-			p2.Fitness++
-			p1.Fitness--
+		p.Fitness /= float64(s.Samples)
+		log.Printf("fitness of %d: %.4f\n", i, p.Fitness)
+	}
+}
+
+// play every pair of particles Samples times and set fitness to the Elo rating
+// given by a Bradley-Terry fit of the results
+func (s *Swarm) evalRoundRobin() {
+	n := len(s.Particles)
+	index := make(map[*Particle]int)
+	for i, p := range s.Particles {
+		index[p] = i
+	}
+	matches := make([]*Match, 0)
+	for sample := uint(0); sample < s.Samples; sample++ {
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				black, white := i, j
+				if (i+j+int(sample))%2 == 1 {
+					black, white = j, i
+				}
+				matches = append(matches, &Match{Black: s.Particles[black], White: s.Particles[white]})
+			}
 		}
 	}
+	s.play(matches)
+	wins := make([][]float64, n)
+	for i := range wins {
+		wins[i] = make([]float64, n)
+	}
+	for _, m := range matches {
+		black, white := index[m.Black], index[m.White]
+		switch m.Winner {
+		case BLACK:
+			wins[black][white]++
+		case WHITE:
+			wins[white][black]++
+		}
+	}
+	ratings := bradleyTerry(wins)
+	for i := range s.Particles {
+		s.Particles[i].Fitness = ratings[i]
+		log.Printf("fitness of %d: %.4f\n", i, s.Particles[i].Fitness)
+	}
+}
+
+/*
+	fit Bradley-Terry strengths to a matrix of wins[i][j] (games i won against j)
+	using the minorization-maximization algorithm, returns Elo ratings
+	each player gets one virtual win and loss against a player of strength 1
+	so that undefeated or winless players still have a finite rating
+*/
+func bradleyTerry(wins [][]float64) []float64 {
+	n := len(wins)
+	gamma := make([]float64, n)
+	for i := range gamma {
+		gamma[i] = 1
+	}
+	for iter := 0; iter < 100; iter++ {
+		next := make([]float64, n)
+		delta := 0.0
+		for i := 0; i < n; i++ {
+			w := 1.0
+			d := 2 / (gamma[i] + 1)
+			for j := 0; j < n; j++ {
+				if i == j {
+					continue
+				}
+				w += wins[i][j]
+				if games := wins[i][j] + wins[j][i]; games > 0 {
+					d += games / (gamma[i] + gamma[j])
+				}
+			}
+			next[i] = w / d
+			delta = math.Fmax(delta, math.Fabs(next[i]-gamma[i]))
+		}
+		gamma = next
+		if delta < 1e-6 {
+			break
+		}
+	}
+	ratings := make([]float64, n)
+	for i := range gamma {
+		ratings[i] = 400 * math.Log10(gamma[i])
+	}
+	return ratings
 }
 
 /**
@@ -177,10 +422,16 @@ func (s *Swarm) step() {
 	}
 
 	// evaluate either children (,) or children + parents (+) for fitness
-	for i := range s.Particles {
-		log.Printf("evaluating %d/%d\n", i, len(s.Particles))
-		s.evalPlay(s.Particles[i], randParticle(s.Particles, []*Particle{s.Particles[i]}))
-		log.Printf("fitness of %d: %.4f\n", i, s.Particles[i].Fitness)
+	switch s.config.EvalMode {
+	case "reference":
+		s.evalReference()
+	case "roundrobin":
+		s.evalRoundRobin()
+	default:
+		s.evalPlay()
+		for i := range s.Particles {
+			log.Printf("fitness of %d: %.4f\n", i, s.Particles[i].Fitness)
+		}
 	}
 
 	// select mu parents from either children (,) or children + parents (+)
@@ -300,6 +551,8 @@ func (s *Swarm) LoadSwarm(filename string, config *Config) {
 		panic(err)
 	}
 	defer func() { f.Close() }()
+	// don't decode the saved config into the caller's config
+	s.TrainConfig = nil
 	d := gob.NewDecoder(f)
 	err = d.Decode(s)
 	if err != nil {
@@ -308,6 +561,10 @@ func (s *Swarm) LoadSwarm(filename string, config *Config) {
 	s.config = config
 	for i := range s.Particles {
 		s.Particles[i].swarm = s
+		// checkpoints written before particles had seeds would all fill in the same weights
+		if s.Particles[i].Seed == 0 {
+			s.Particles[i].Seed = rand.Int63()
+		}
 	}
 }
 
@@ -320,20 +577,176 @@ func LoadBest(filename string, config *Config) *Particle {
 	return s.Best()
 }
 
+// load the frozen reference particle, nil if there is none (plays with no weights)
+func LoadReference(config *Config) *Particle {
+	if config.Reference == "" {
+		return nil
+	}
+	return LoadBest(config.Reference, config)
+}
+
+// play the best particle against the reference and append the winrate to the strength curve
+// returns the winrate, or -1 if the strength curve is disabled
+func (s *Swarm) logStrength() float64 {
+	if s.config.Curve == 0 {
+		return -1
+	}
+	winrate := s.winrate(s.Best(), s.config.Curve)
+	log.Printf("generation %d, winrate against reference: %.4f\n", s.Generation, winrate)
+	var filename string
+	if s.config.Prefix != "" {
+		filename = s.config.Prefix + ".strength.txt"
+	} else {
+		filename = "strength.txt"
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		panic(err)
+	}
+	defer func() { f.Close() }()
+	fmt.Fprintf(f, "%d %.4f\n", s.Generation, winrate)
+	return winrate
+}
+
+func (s *Swarm) stats() (stats GenerationStats) {
+	stats.Generation = s.Generation
+	stats.Best = s.Best().Fitness
+	stats.Min, stats.Max = math.Inf(1), math.Inf(-1)
+	for i := range s.Particles {
+		fitness := s.Particles[i].Fitness
+		stats.Mean += fitness / float64(len(s.Particles))
+		stats.Min = math.Fmin(stats.Min, fitness)
+		stats.Max = math.Fmax(stats.Max, fitness)
+	}
+	return
+}
+
+// flags restoreConfig takes from the command line rather than the checkpoint
+var resume_flags = map[string]bool{
+	"train": true, "sfile": true, "log": true, "curve": true,
+	"distributed": true, "job_retries": true, "job_timeout": true,
+	"transport": true, "cluster_addr": true, "broker": true, "workers": true, "heartbeat": true,
+	"v": true, "vv": true, "verify": true, "printweights": true, "stats": true,
+}
+
+/*
+	Continue training the experiment saved in the checkpoint: its game, search and
+	learning parameters are restored, while the checkpoint being resumed from, logging,
+	the strength curve and the cluster come from the command line, as do more generations
+	other flags given on the command line are overridden, which is logged
+*/
+func (s *Swarm) restoreConfig(config *Config) {
+	if s.TrainConfig == nil {
+		s.TrainConfig = config
+		return
+	}
+	saved := *s.TrainConfig
+	flag.Visit(func(f *flag.Flag) {
+		if !resume_flags[f.Name] && (f.Name != "gens" || config.Generations <= saved.Generations) {
+			log.Printf("-%s is overridden by the checkpoint\n", f.Name)
+		}
+	})
+	saved.Sfile = config.Sfile
+	saved.Lfile = config.Lfile
+	if config.Generations > saved.Generations {
+		saved.Generations = config.Generations
+	}
+	saved.Curve = config.Curve
+	saved.Distributed, saved.JobRetries, saved.JobTimeout = config.Distributed, config.JobRetries, config.JobTimeout
+	saved.Transport, saved.ClusterAddr, saved.Broker = config.Transport, config.ClusterAddr, config.Broker
+	saved.Workers, saved.Heartbeat = config.Workers, config.Heartbeat
+	saved.Verbose, saved.VeryVerbose, saved.Verify = config.Verbose, config.VeryVerbose, config.Verify
+	saved.PrintWeights, saved.Stats = config.PrintWeights, config.Stats
+	saved.cfile = config.cfile
+	saved.book = config.book
+	saved.policy_weights = config.policy_weights
+	saved.probLog = config.probLog
+	*config = saved
+	s.TrainConfig = config
+	s.config = config
+}
+
 func Train(config *Config) {
 	var s *Swarm
 	s = NewSwarm(config)
 	if config.Sfile != "" {
 		s.LoadSwarm(config.Sfile, config)
+		s.restoreConfig(config)
+		log.Printf("resuming from generation %d\n", s.Generation)
+	}
+	s.reference = LoadReference(config)
+	if config.Distributed {
+		s.transport = NewTransport(config)
+		results, err := s.transport.Subscribe("results")
+		if err != nil {
+			log.Println("cannot subscribe to cluster results, evaluating locally:", err)
+			config.Distributed = false
+		}
+		s.results = results
 	}
 	for s.Generation < s.config.Generations {
 		start := time.Nanoseconds()
+		// reseed each generation so a resumed run continues exactly where it stopped
+		rand.Seed(s.Seed + int64(s.Generation))
 		s.step()
 		s.Generation++
+		stats := s.stats()
+		stats.Strength = s.logStrength()
+		stats.Started = start
+		stats.Seconds = float64(time.Nanoseconds()-start) / 1e9
+		s.History = append(s.History, stats)
 		s.SaveSwarm()
-		log.Printf("generation %d/%d, best: %.4f, took %d seconds",
-			s.Generation, s.config.Generations, s.Best().Fitness,
-			(time.Nanoseconds()-start)/1e9)
+		log.Printf("generation %d/%d, best: %.4f, took %.0f seconds",
+			s.Generation, s.config.Generations, stats.Best, stats.Seconds)
+	}
+}
+
+type Checkpoints []*Swarm
+
+func (c Checkpoints) Len() int {
+	return len(c)
+}
+
+func (c Checkpoints) Less(i, j int) bool {
+	return c[i].Generation < c[j].Generation
+}
+
+func (c Checkpoints) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// print a table of the swarm checkpoints in dir
+func Summary(dir string, config *Config) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*swarm.*.gob"))
+	if err != nil {
+		panic(err)
+	}
+	checkpoints := make(Checkpoints, len(filenames))
+	for i := range filenames {
+		checkpoints[i] = new(Swarm)
+		checkpoints[i].LoadSwarm(filenames[i], config)
+	}
+	sort.Sort(checkpoints)
+	fmt.Printf("%5s %9s %9s %9s %9s %9s %9s\n", "gen", "best", "mean", "min", "max", "strength", "seconds")
+	for _, s := range checkpoints {
+		var stats GenerationStats
+		if len(s.History) > 0 {
+			stats = s.History[len(s.History)-1]
+		} else {
+			// checkpoints saved before metadata was recorded
+			stats = s.stats()
+			stats.Strength = -1
+		}
+		fmt.Printf("%5d %9.4f %9.4f %9.4f %9.4f %9.4f %9.0f\n",
+			s.Generation, stats.Best, stats.Mean, stats.Min, stats.Max, stats.Strength, stats.Seconds)
+	}
+	if len(checkpoints) > 0 {
+		last := checkpoints[len(checkpoints)-1]
+		if last.TrainConfig != nil {
+			c := last.TrainConfig
+			fmt.Printf("seed: %d, mu: %d, lambda: %d, parents: %d, samples: %d, eval: %s\n",
+				last.Seed, c.Mu, c.Lambda, c.Parents, c.Samples, c.EvalMode)
+		}
 	}
 }
 
@@ -371,6 +784,88 @@ func drawHex(xoff, yoff, width float64, pos int, style1, style2 string, s *svg.S
 	}
 }
 
+// draw a 3x3 Go pattern with its top-left corner at (xoff, yoff)
+// off-board cells are shaded and the board edge is drawn as a thick line
+func drawGoPattern(xoff, yoff, width int, a []byte, s *svg.SVG) {
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			x, y := xoff+col*width, yoff+row*width
+			if a[row*3+col] == ILLEGAL {
+				s.Rect(x, y, width, width, "fill:#8a8a8a;stroke:#8a8a8a")
+				continue
+			}
+			s.Rect(x, y, width, width, "fill:#dcb35c;stroke:none")
+			c := width / 2
+			s.Line(x, y+c, x+width, y+c, "stroke:#464646;stroke-width:1")
+			s.Line(x+c, y, x+c, y+width, "stroke:#464646;stroke-width:1")
+			switch a[row*3+col] {
+			case BLACK:
+				s.Circle(x+c, y+c, c-2, "fill:black;stroke:black;stroke-width:2")
+			case WHITE:
+				s.Circle(x+c, y+c, c-2, "fill:white;stroke:black;stroke-width:2")
+			case EMPTY:
+				if row == 1 && col == 1 {
+					s.Circle(x+c, y+c, c/3, "fill:red;stroke:none")
+				}
+			}
+		}
+	}
+	edge := "stroke:black;stroke-width:4"
+	if a[1] == ILLEGAL {
+		s.Line(xoff, yoff+width, xoff+3*width, yoff+width, edge)
+	}
+	if a[7] == ILLEGAL {
+		s.Line(xoff, yoff+2*width, xoff+3*width, yoff+2*width, edge)
+	}
+	if a[3] == ILLEGAL {
+		s.Line(xoff+width, yoff, xoff+width, yoff+3*width, edge)
+	}
+	if a[5] == ILLEGAL {
+		s.Line(xoff+2*width, yoff, xoff+2*width, yoff+3*width, edge)
+	}
+}
+
+type GoPattern struct {
+	board                      []byte
+	black, white               float64
+	expert_black, expert_white float64
+}
+
+type GoPatterns []*GoPattern
+
+func (p GoPatterns) Len() int {
+	return len(p)
+}
+
+func (p GoPatterns) Less(i, j int) bool {
+	if p[i].black != p[j].black {
+		return p[i].black > p[j].black
+	}
+	return p[i].white > p[j].white
+}
+
+func (p GoPatterns) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+// canonical 3x3 patterns that have either a learned or expert weight, sorted by black weight
+func go_patterns(p *Particle, config *Config) GoPatterns {
+	patterns := make(GoPatterns, 0)
+	for hash, a := range pattern_boards(config) {
+		white := pattern_hash(WHITE, a, config)
+		_, learned := p.Position[hash]
+		expert_black, black_exists := go_expert_policy_weights[hash]
+		expert_white, white_exists := go_expert_policy_weights[white]
+		if !learned && !black_exists && !white_exists {
+			continue
+		}
+		patterns = append(patterns, &GoPattern{
+			a, p.Position[hash], p.Position[white], expert_black, expert_white})
+	}
+	sort.Sort(patterns)
+	return patterns
+}
+
 func PrintBestWeights(config *Config) {
 	f, err := os.Create(config.Sfile + ".svg")
 	if err != nil {
@@ -378,6 +873,20 @@ func PrintBestWeights(config *Config) {
 	}
 	p := LoadBest(config.Sfile, config)
 	s := svg.New(f)
+	if config.Go {
+		w := 20
+		height := 4 * w
+		patterns := go_patterns(p, config)
+		s.Start(16*w, height*len(patterns)+w)
+		for i, pattern := range patterns {
+			x, y := w/2, w/2+i*height
+			drawGoPattern(x, y, w, pattern.board, s)
+			s.Text(x+4*w, y+w-5, fmt.Sprintf("B: %f", pattern.black), "font-family:monospace")
+			s.Text(x+4*w, y+2*w+5, fmt.Sprintf("W: %f", pattern.white), "font-family:monospace")
+			s.Text(x+10*w, y+w-5, fmt.Sprintf("expert B: %.2f", pattern.expert_black), "font-family:monospace")
+			s.Text(x+10*w, y+2*w+5, fmt.Sprintf("expert W: %.2f", pattern.expert_white), "font-family:monospace")
+		}
+	}
 	if config.Hex {
 		v := new(vector.Vector)
 		a := []byte{EMPTY, EMPTY, EMPTY, EMPTY, EMPTY, EMPTY, EMPTY}
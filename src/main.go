@@ -15,7 +15,7 @@ func main() {
 
 	shutdown := make(chan bool, 1)
 	if config.Cluster {
-		InitCluster(shutdown)
+		InitCluster(config, NewTransport(config), shutdown)
 	} else {
 		shutdown <- true
 	}
@@ -23,10 +23,58 @@ func main() {
 	if config.Help {
 		flag.Usage()
 		os.Exit(0)
+	} else if config.Broker {
+		Broker(config.ClusterAddr)
+	} else if config.Status {
+		Status(NewTransport(config), config)
+	} else if config.Submit != "" {
+		Submit(NewTransport(config), config.Submit, config)
 	} else if config.Gtp {
 		GTP(config)
+	} else if config.Solve {
+		t, color := NewTracker(config), BLACK
+		if config.SGF != "" {
+			t, color = Load(config.SGF, config)
+		}
+		hex, ok := t.(*HexTracker)
+		if !ok {
+			panic("only hex can be solved")
+		}
+		solver := NewHexSolver(config)
+		start := time.Nanoseconds()
+		winner, vertex := solver.Solve(hex, color)
+		fmt.Println(t.String())
+		if winner == EMPTY {
+			fmt.Printf("%s to play, unknown after %d positions\n", Ctoa(color), solver.Nodes)
+		} else if winner == color && vertex != -1 {
+			fmt.Printf("%s to play wins with %s\n", Ctoa(color), t.Vtoa(vertex))
+		} else {
+			fmt.Printf("%s to play, %s wins\n", Ctoa(color), Ctoa(winner))
+		}
+		log.Printf("%d positions in %.2fs\n", solver.Nodes, float64(time.Nanoseconds()-start)/1e9)
+	} else if config.Tsumego != "" {
+		t, color := NewTracker(config), BLACK
+		if config.SGF != "" {
+			t, color = Load(config.SGF, config)
+		}
+		gotracker, ok := t.(*GoTracker)
+		if !ok {
+			panic("tsumego needs a go position")
+		}
+		start := time.Nanoseconds()
+		status, vertex, err := TsumegoStatus(gotracker, color, config.Tsumego, config.Region, config)
+		if err != nil {
+			panic(err.String())
+		}
+		fmt.Println(t.String())
+		if vertex != -1 {
+			fmt.Printf("%s to play, %s is %s after %s\n", Ctoa(color), config.Tsumego, status, t.Vtoa(vertex))
+		} else {
+			fmt.Printf("%s to play, %s is %s\n", Ctoa(color), config.Tsumego, status)
+		}
+		log.Printf("searched in %.2fs\n", float64(time.Nanoseconds()-start)/1e9)
 	} else if config.SGF != "" {
-		t, color := Load(config.SGF)
+		t, color := Load(config.SGF, config)
 		root := NewRoot(color, t, config)
 		genmove(root, t)
 		vertex := root.Best().Vertex
@@ -35,13 +83,24 @@ func main() {
 		fmt.Println(t.String())
 	} else if config.Train {
 		Train(config)
+	} else if config.Summary != "" {
+		Summary(config.Summary, config)
+	} else if config.Tune {
+		Tune(config)
 	} else if config.Book {
-		t := NewTracker(config)
-		genmove(config.book, t)
-		config.book.SaveBook()
-		if config.Verbose {
-			log.Println(config.book.String(0, 2, t))
-		}
+		BuildBook(config)
+	} else if config.BookDump != "" {
+		DumpBook(config.book, config.BookDump)
+	} else if config.BookImport != "" {
+		ImportBook(config.BookImport, config)
+		config.book.Save()
+	} else if config.BookMerge != "" {
+		MergeBooks(config.BookMerge, config)
+		config.book.Save()
+	} else if config.ExportPatterns != "" {
+		ExportPatterns(LoadBest(config.Sfile, config), config.ExportPatterns, config)
+	} else if config.ImportPatterns != "" {
+		ImportPatterns(config.ImportPatterns, config).SaveSwarm()
 	} else if config.PrintWeights {
 		PrintBestWeights(config)
 		shutdown <- true
@@ -57,7 +116,7 @@ func main() {
 			for {
 				var vertex int
 				if move == 0 && config.Swapsafe {
-					vertex = (3 * t.Boardsize()) + 2
+					vertex = (3 * t.Width()) + 2
 				} else {
 					root := NewRoot(color, t, config)
 					genmove(root, t)
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"container/vector"
+	"fmt"
+	"log"
+	"rand"
+	"strconv"
+	"strings"
+)
+
+// the eight directions as bitboard shifts: east, west, south, north, south east, north west, south west, north east
+var othello_shifts = [8]int{1, -1, 8, -8, 9, -9, 7, -7}
+
+// whether Othello can be played on a size x size board
+func othello_size(size int) bool {
+	return size%2 == 0 && size >= 4 && size <= 8
+}
+
+/*
+	Othello (Reversi) on an even board of up to 8x8
+	the discs of each color are kept in a bitboard with rows of 8 bits, so moves are
+	generated and flipped with shifts, board mirrors the bitboards for the Tracker interface
+	a player with no move must pass, and pass is only legal then
+	the game ends when neither player can move, the winner has more discs, BOTH on a tie
+*/
+type OthelloTracker struct {
+	boardsize int
+	sqsize    int
+	board     []byte
+	played    []byte
+	discs     [3]uint64
+	// legal moves of each color, generated after every move
+	legal [3]uint64
+	// squares on the board, and the squares a shift in each direction may land on
+	mask   uint64
+	masks  [8]uint64
+	winner byte
+	moves  *vector.IntVector
+	config *Config
+}
+
+func NewOthelloTracker(config *Config) *OthelloTracker {
+	if !othello_size(config.Size) {
+		panic(fmt.Sprintf("Othello is played on even boards from 4x4 to 8x8, not %dx%d", config.Size, config.Size))
+	}
+	t := new(OthelloTracker)
+
+	t.boardsize = config.Size
+	t.sqsize = t.boardsize * t.boardsize
+	t.board = make([]byte, t.sqsize)
+	t.played = make([]byte, t.sqsize)
+
+	for v := 0; v < t.sqsize; v++ {
+		t.mask |= t.bit(v)
+	}
+	east, west := t.mask, t.mask
+	for row := 0; row < 8; row++ {
+		east &^= 1 << uint(row*8)
+		west &^= 1 << uint(row*8+7)
+	}
+	t.masks = [8]uint64{east, west, t.mask, t.mask, east, west, west, east}
+
+	t.winner = EMPTY
+
+	t.moves = new(vector.IntVector)
+
+	t.config = config
+
+	// the four center squares, white on the diagonal
+	c := t.boardsize/2 - 1
+	t.place(WHITE, c*t.boardsize+c)
+	t.place(BLACK, c*t.boardsize+c+1)
+	t.place(BLACK, (c+1)*t.boardsize+c)
+	t.place(WHITE, (c+1)*t.boardsize+c+1)
+	t.generate()
+
+	return t
+}
+
+func (t *OthelloTracker) Copy() Tracker {
+	cp := new(OthelloTracker)
+
+	cp.boardsize = t.boardsize
+	cp.sqsize = t.sqsize
+	cp.board = make([]byte, cp.sqsize)
+	copy(cp.board, t.board)
+	cp.played = make([]byte, cp.sqsize)
+	cp.discs = t.discs
+	cp.legal = t.legal
+	cp.mask = t.mask
+	cp.masks = t.masks
+
+	cp.winner = t.winner
+
+	cp.moves = new(vector.IntVector)
+	*cp.moves = t.moves.Copy()
+
+	cp.config = t.config
+
+	return cp
+}
+
+// bitboard bit of vertex
+func (t *OthelloTracker) bit(vertex int) uint64 {
+	return 1 << uint((vertex/t.boardsize)*8+vertex%t.boardsize)
+}
+
+// vertex of bitboard bit i
+func (t *OthelloTracker) vertex(i int) int {
+	return (i/8)*t.boardsize + i%8
+}
+
+func (t *OthelloTracker) shift(b uint64, d int) uint64 {
+	if othello_shifts[d] > 0 {
+		b <<= uint(othello_shifts[d])
+	} else {
+		b >>= uint(-othello_shifts[d])
+	}
+	return b & t.masks[d]
+}
+
+func (t *OthelloTracker) place(color byte, vertex int) {
+	t.discs[color] |= t.bit(vertex)
+	t.discs[Reverse(color)] &^= t.bit(vertex)
+	t.board[vertex] = color
+}
+
+// legal moves of color: empty squares at the end of a line of opponent discs starting at an own disc
+func (t *OthelloTracker) generate() {
+	for _, color := range []byte{BLACK, WHITE} {
+		own, opp := t.discs[color], t.discs[Reverse(color)]
+		empty := t.mask &^ (own | opp)
+		t.legal[color] = 0
+		for d := range othello_shifts {
+			x := t.shift(own, d) & opp
+			// a line holds at most 6 opponent discs
+			for i := 0; i < 5; i++ {
+				x |= t.shift(x, d) & opp
+			}
+			t.legal[color] |= t.shift(x, d) & empty
+		}
+	}
+}
+
+// discs flipped by color playing at vertex
+func (t *OthelloTracker) flips(color byte, vertex int) uint64 {
+	own, opp := t.discs[color], t.discs[Reverse(color)]
+	flips := uint64(0)
+	for d := range othello_shifts {
+		line := uint64(0)
+		x := t.shift(t.bit(vertex), d)
+		for x&opp != 0 {
+			line |= x
+			x = t.shift(x, d)
+		}
+		if x&own != 0 {
+			flips |= line
+		}
+	}
+	return flips
+}
+
+func (t *OthelloTracker) Play(color byte, vertex int) {
+	if vertex != -1 {
+		if t.board[vertex] != EMPTY {
+			log.Println(t.String())
+			log.Println(Ctoa(color), t.Vtoa(vertex))
+			panic("play on non-empty vertex")
+		}
+		flips := t.flips(color, vertex)
+		t.place(color, vertex)
+		for i := 0; flips != 0; i++ {
+			if flips&1 != 0 {
+				t.place(color, t.vertex(i))
+			}
+			flips >>= 1
+		}
+		if t.played[vertex] == EMPTY {
+			t.played[vertex] = color
+		}
+	}
+	t.generate()
+	if t.winner == EMPTY && t.legal[BLACK] == 0 && t.legal[WHITE] == 0 {
+		bc, wc := t.Score(0)
+		if bc > wc {
+			t.winner = BLACK
+		} else if wc > bc {
+			t.winner = WHITE
+		} else {
+			t.winner = BOTH
+		}
+	}
+	t.moves.Push(vertex)
+}
+
+// number of bits set in b
+func othello_count(b uint64) (n int) {
+	for ; b != 0; b &= b - 1 {
+		n++
+	}
+	return
+}
+
+// a random legal move of color, corners first as they can never be flipped
+func (t *OthelloTracker) random(color byte) int {
+	moves := t.legal[color]
+	corners := moves & (t.bit(0) | t.bit(t.boardsize-1) | t.bit(t.sqsize-t.boardsize) | t.bit(t.sqsize-1))
+	if corners != 0 {
+		moves = corners
+	}
+	if moves == 0 {
+		return -1
+	}
+	n := rand.Intn(othello_count(moves))
+	for i := 0; ; i++ {
+		if moves&(1<<uint(i)) != 0 {
+			if n == 0 {
+				return t.vertex(i)
+			}
+			n--
+		}
+	}
+	return -1
+}
+
+func (t *OthelloTracker) Playout(color byte) {
+	for t.winner == EMPTY {
+		vertex := t.random(color)
+		if t.config.VeryVerbose {
+			log.Println(Ctoa(color) + t.Vtoa(vertex))
+		}
+		t.Play(color, vertex)
+		if t.config.VeryVerbose {
+			log.Println(t.String())
+		}
+		if t.config.Verify {
+			t.Verify()
+		}
+		color = Reverse(color)
+	}
+	if t.config.VeryVerbose {
+		log.Println("FINAL: " + Ctoa(t.winner))
+	}
+}
+
+func (t *OthelloTracker) WasPlayed(color byte, vertex int) bool {
+	if vertex == -1 {
+		return false
+	}
+	return t.played[vertex] == color
+}
+
+// nothing is legal once the game is over
+func (t *OthelloTracker) Legal(color byte, vertex int) bool {
+	if t.winner != EMPTY {
+		return false
+	}
+	if vertex == -1 {
+		return t.legal[color] == 0
+	}
+	return t.legal[color]&t.bit(vertex) != 0
+}
+
+// disc count
+func (t *OthelloTracker) Score(Komi float64) (float64, float64) {
+	return float64(othello_count(t.discs[BLACK])), float64(othello_count(t.discs[WHITE])) + Komi
+}
+
+func (t *OthelloTracker) Winner() byte {
+	return t.winner
+}
+
+func (t *OthelloTracker) SetKomi(Komi float64) {
+
+}
+
+func (t *OthelloTracker) GetKomi() float64 {
+	return 0
+}
+
+func (t *OthelloTracker) Width() int {
+	return t.boardsize
+}
+
+func (t *OthelloTracker) Height() int {
+	return t.boardsize
+}
+
+func (t *OthelloTracker) Sqsize() int {
+	return t.sqsize
+}
+
+func (t *OthelloTracker) Board() []byte {
+	return t.board
+}
+
+func (t *OthelloTracker) Territory(color byte) []float64 {
+	territory := make([]float64, t.sqsize)
+	for i := range t.board {
+		if t.board[i] == color {
+			territory[i] = 1
+		}
+	}
+	return territory
+}
+
+func (t *OthelloTracker) Verify() {
+	for v := range t.board {
+		for _, color := range []byte{BLACK, WHITE} {
+			if (t.board[v] == color) != (t.discs[color]&t.bit(v) != 0) {
+				panic("bitboard disagrees with board at " + t.Vtoa(v))
+			}
+		}
+		for _, color := range []byte{BLACK, WHITE} {
+			if t.legal[color]&t.bit(v) != 0 && (t.board[v] != EMPTY || t.flips(color, v) == 0) {
+				panic(Ctoa(color) + " " + t.Vtoa(v) + " is not a legal move")
+			}
+		}
+	}
+	if t.discs[BLACK]&t.discs[WHITE] != 0 || (t.discs[BLACK]|t.discs[WHITE])&^t.mask != 0 {
+		panic("bitboards overlap or leave the board")
+	}
+}
+
+// the eight vertices around vertex, -1 where they are off the board
+func (t *OthelloTracker) Adj(vertex int) []int {
+	adj := make([]int, 8)
+	for d := range othello_shifts {
+		adj[d] = -1
+		x := t.shift(t.bit(vertex), d)
+		for i := 0; x != 0; i++ {
+			if x == 1 {
+				adj[d] = t.vertex(i)
+			}
+			x >>= 1
+		}
+	}
+	return adj
+}
+
+func (t *OthelloTracker) Moves() *vector.IntVector {
+	return t.moves
+}
+
+// Othello notation: columns from A, rows from 1 at the top
+func (t *OthelloTracker) Vtoa(v int) string {
+	if v == -1 {
+		return "PASS"
+	}
+	return fmt.Sprintf("%c%d", 'A'+v%t.boardsize, v/t.boardsize+1)
+}
+
+func (t *OthelloTracker) Atov(s string) int {
+	if s == "PASS" || s == "pass" {
+		return -1
+	}
+	col := int(strings.ToUpper(s)[0] - 'A')
+	row, err := strconv.Atoi(s[1:len(s)])
+	if err != nil {
+		panic("Failed to convert string to vertex")
+	}
+	return (row-1)*t.boardsize + col
+}
+
+func (t *OthelloTracker) String() (s string) {
+	s += "  "
+	for col := 0; col < t.boardsize; col++ {
+		s += fmt.Sprintf(" %c", 'A'+col)
+	}
+	for row := 0; row < t.boardsize; row++ {
+		s += fmt.Sprintf("\n%2d", row+1)
+		for col := 0; col < t.boardsize; col++ {
+			s += " " + Ctoa(t.board[row*t.boardsize+col])
+		}
+	}
+	return
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"json"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Human-readable pattern weights
+	each pattern is spelled out as the neighborhood go_hash or hex_hash sees, using
+	. for empty, B and W for stones and X for off the board
+
+	Go patterns are the 3x3 square around the vertex:
+		B.W
+		...
+		XXX
+	Hex patterns are the 7 cells around the vertex, as drawn by HexTracker.String:
+		 B .
+		. . W
+		 X X
+*/
+type PatternFile struct {
+	Game     string
+	Strategy float64
+	Min, Max float64
+	Patterns []*Pattern
+	// weights whose hash does not correspond to any pattern, keyed by hash
+	Extra map[string]float64
+}
+
+type Pattern struct {
+	Grid []string
+	// weights of the pattern for each color to play, null if the particle has none
+	Black, White *float64
+	// local response weights used by Hex playout suggestions, null if unset
+	BlackLocal, WhiteLocal *float64
+}
+
+// hex cells in the order they appear in the grid, row by row
+var hex_grid_order = []int{0, 1, 5, 6, 2, 4, 3}
+
+const LOCAL_PATTERN = 1 << 30
+
+func pattern_hash(color byte, a []byte, config *Config) uint32 {
+	if config.Hex {
+		return hex_min_hash[hex_hash(color, a, []int{0, 1, 2, 3, 4, 5, 6})]
+	}
+	return go_min_hash[go_hash(color, a, []int{0, 1, 2, 3, 4, 5, 6, 7, 8})]
+}
+
+/*
+	Map each canonical black pattern hash to the neighborhood it encodes
+	every cell goes from EMPTY to ILLEGAL as in setup_go_min_hash, starting from the
+	empty neighborhood, and only neighborhoods of an empty vertex on a real board are kept
+*/
+func pattern_boards(config *Config) map[uint32][]byte {
+	boards := make(map[uint32][]byte)
+	size := 9
+	if config.Hex {
+		size = 7
+	}
+	a := make([]byte, size)
+	for n := 0; n < 1<<uint(2*size); n++ {
+		for i := range a {
+			a[i] = byte(n>>uint(2*i)) & 3
+		}
+		if !pattern_valid(a, config) {
+			continue
+		}
+		hash := pattern_hash(BLACK, a, config)
+		if _, exists := boards[hash]; !exists {
+			boards[hash] = mkcp(a)
+		}
+	}
+	return boards
+}
+
+// whether a tracker can hash the neighborhood a of an empty vertex
+func pattern_valid(a []byte, config *Config) bool {
+	if config.Hex {
+		// hex_min_hash has no off-board cells, HexTracker looks those up as hash 0
+		_, exists := hex_min_hash[hex_hash(BLACK, a, []int{0, 1, 2, 3, 4, 5, 6})]
+		return a[6] == EMPTY && exists
+	}
+	return go_pattern_valid(a)
+}
+
+// true if the off-board cells of a 3x3 pattern form whole edges of a board
+func go_pattern_valid(a []byte) bool {
+	top, bottom := a[1] == ILLEGAL, a[7] == ILLEGAL
+	left, right := a[3] == ILLEGAL, a[5] == ILLEGAL
+	if (top && bottom) || (left && right) || a[4] != EMPTY {
+		return false
+	}
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			edge := (row == 0 && top) || (row == 2 && bottom) || (col == 0 && left) || (col == 2 && right)
+			if edge != (a[row*3+col] == ILLEGAL) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func pattern_grid(a []byte, config *Config) []string {
+	if config.Hex {
+		c := make([]string, 7)
+		for i, j := range hex_grid_order {
+			c[i] = Ctoa(a[j])
+		}
+		return []string{
+			" " + c[0] + " " + c[1],
+			c[2] + " " + c[3] + " " + c[4],
+			" " + c[5] + " " + c[6],
+		}
+	}
+	grid := make([]string, 3)
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			grid[row] += Ctoa(a[row*3+col])
+		}
+	}
+	return grid
+}
+
+func parse_grid(grid []string, config *Config) []byte {
+	cells := strings.Replace(strings.Join(grid, ""), " ", "", -1)
+	if config.Hex {
+		if len(cells) != 7 {
+			panic("hex pattern must have 7 cells: " + strings.Join(grid, "/"))
+		}
+		a := make([]byte, 7)
+		for i, j := range hex_grid_order {
+			a[j] = Atoc(cells[i : i+1])
+		}
+		return a
+	}
+	if len(cells) != 9 {
+		panic("go pattern must have 9 cells: " + strings.Join(grid, "/"))
+	}
+	a := make([]byte, 9)
+	for i := range a {
+		a[i] = Atoc(cells[i : i+1])
+	}
+	return a
+}
+
+type PatternHashes []uint32
+
+func (h PatternHashes) Len() int {
+	return len(h)
+}
+
+func (h PatternHashes) Less(i, j int) bool {
+	return h[i] < h[j]
+}
+
+func (h PatternHashes) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+// pattern files hold the 3x3 Go and Hex neighborhoods, other games hash theirs differently
+func check_pattern_game(config *Config) {
+	if !(config.Go || config.Hex) {
+		panic("pattern files are only for go and hex, not " + GameName(config))
+	}
+}
+
+// write the weights of p as a pattern file
+func ExportPatterns(p *Particle, filename string, config *Config) {
+	check_pattern_game(config)
+	pf := new(PatternFile)
+	pf.Game = "go"
+	if config.Hex {
+		pf.Game = "hex"
+	}
+	pf.Strategy, pf.Min, pf.Max = p.Strategy, p.Min, p.Max
+	pf.Extra = make(map[string]float64)
+	boards := pattern_boards(config)
+	used := make(map[uint32]bool)
+	// in order, so the file is the same every time the same weights are exported
+	hashes := make(PatternHashes, 0, len(p.Position))
+	for hash := range p.Position {
+		hashes = append(hashes, hash)
+	}
+	sort.Sort(hashes)
+	for _, hash := range hashes {
+		black := hash &^ (1<<31 | LOCAL_PATTERN)
+		a, exists := boards[black]
+		if !exists {
+			pf.Extra[strconv.Uitoa64(uint64(hash))] = p.Position[hash]
+			continue
+		}
+		if used[black] {
+			continue
+		}
+		used[black] = true
+		pattern := &Pattern{Grid: pattern_grid(a, config)}
+		white := pattern_hash(WHITE, a, config)
+		if w, exists := p.Position[black]; exists {
+			pattern.Black = &w
+		}
+		if w, exists := p.Position[white]; exists {
+			pattern.White = &w
+		}
+		if w, exists := p.Position[black|LOCAL_PATTERN]; exists {
+			pattern.BlackLocal = &w
+		}
+		if w, exists := p.Position[white|LOCAL_PATTERN]; exists {
+			pattern.WhiteLocal = &w
+		}
+		pf.Patterns = append(pf.Patterns, pattern)
+	}
+	bytes, err := json.MarshalIndent(pf, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer func() { f.Close() }()
+	f.Write(bytes)
+}
+
+// read a pattern file into a single particle swarm
+func ImportPatterns(filename string, config *Config) *Swarm {
+	check_pattern_game(config)
+	f, err := os.Open(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer func() { f.Close() }()
+	pf := new(PatternFile)
+	if err = json.NewDecoder(f).Decode(pf); err != nil {
+		panic(err)
+	}
+	if (pf.Game == "hex") != config.Hex {
+		panic(fmt.Sprintf("%s contains %s patterns", filename, pf.Game))
+	}
+	s := new(Swarm)
+	s.config = config
+	s.Mu = 1
+	p := NewParticle(s, pf.Min, pf.Max)
+	p.Strategy = pf.Strategy
+	p.Fitness = 1
+	for _, pattern := range pf.Patterns {
+		a := parse_grid(pattern.Grid, config)
+		black, white := pattern_hash(BLACK, a, config), pattern_hash(WHITE, a, config)
+		if pattern.Black != nil {
+			p.Position[black] = *pattern.Black
+		}
+		if pattern.White != nil {
+			p.Position[white] = *pattern.White
+		}
+		if pattern.BlackLocal != nil {
+			p.Position[black|LOCAL_PATTERN] = *pattern.BlackLocal
+		}
+		if pattern.WhiteLocal != nil {
+			p.Position[white|LOCAL_PATTERN] = *pattern.WhiteLocal
+		}
+	}
+	for key, weight := range pf.Extra {
+		hash, err := strconv.Atoui64(key)
+		if err != nil {
+			log.Println("skipping extra weight", key, err)
+			continue
+		}
+		p.Position[uint32(hash)] = weight
+	}
+	s.Particles = Particles{p}
+	return s
+}
+
+// load policy weights from either a gob swarm or a json pattern file
+func LoadPolicy(filename string, config *Config) *Particle {
+	if strings.HasSuffix(filename, ".json") {
+		return ImportPatterns(filename, config).Best()
+	}
+	return LoadBest(filename, config)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/etherealmachine/redis.go"
+	"os"
+	"sync"
+	"time"
+)
+
+type RedisTransport struct {
+	client redis.Client
+	lock   sync.Mutex
+	// the unsubscribe channel of each subscription, and a channel closed to stop forwarding
+	unsubscribe map[<-chan []byte]chan string
+	done        map[<-chan []byte]chan bool
+}
+
+func NewRedisTransport(addr string) *RedisTransport {
+	r := new(RedisTransport)
+	r.unsubscribe = make(map[<-chan []byte]chan string)
+	r.done = make(map[<-chan []byte]chan bool)
+	if addr == "" {
+		addr = "127.0.0.1:6379"
+	}
+	r.client.Addr = addr
+	return r
+}
+
+func (r *RedisTransport) Publish(channel string, msg []byte) os.Error {
+	return r.client.Publish(channel, msg)
+}
+
+func (r *RedisTransport) Subscribe(channel string) (<-chan []byte, os.Error) {
+	subscriptions := make(chan string, 1)
+	subscriptions <- channel
+	unsubscriptions := make(chan string, 1)
+	messages := make(chan redis.Message)
+	out := make(chan []byte, 1024)
+	done := make(chan bool)
+	errors := make(chan os.Error, 1)
+	go func() {
+		errors <- r.client.Subscribe(subscriptions, unsubscriptions, nil, nil, messages)
+	}()
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				out <- message.Message
+			case <-done:
+				return
+			}
+		}
+	}()
+	// Subscribe only returns early if it failed to connect
+	select {
+	case err := <-errors:
+		close(done)
+		return nil, err
+	case <-time.After(1e8):
+	}
+	r.lock.Lock()
+	r.unsubscribe[out], r.done[out] = unsubscriptions, done
+	r.lock.Unlock()
+	return out, nil
+}
+
+func (r *RedisTransport) Unsubscribe(channel string, messages <-chan []byte) os.Error {
+	r.lock.Lock()
+	unsubscriptions, ok := r.unsubscribe[messages]
+	done := r.done[messages]
+	r.unsubscribe[messages] = nil, false
+	r.done[messages] = nil, false
+	r.lock.Unlock()
+	if !ok {
+		return os.NewError("not subscribed to " + channel)
+	}
+	unsubscriptions <- channel
+	close(done)
+	return nil
+}
+
+func (r *RedisTransport) Push(queue string, msg []byte) os.Error {
+	return r.client.Rpush(queue, msg)
+}
+
+func (r *RedisTransport) Pop(queue string, timeout int64) ([]byte, os.Error) {
+	// redis blocks forever on a timeout of 0
+	seconds := uint(timeout / 1e9)
+	if seconds == 0 {
+		seconds = 1
+	}
+	key, msg, err := r.client.Blpop([]string{queue}, seconds)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -3,10 +3,8 @@ package main
 import (
 	"container/vector"
 	"fmt"
-	"gob"
 	"log"
 	"math"
-	"os"
 	"rand"
 	"time"
 )
@@ -250,6 +248,9 @@ func (root *Node) step(t Tracker) {
 	root.update_time += time.Nanoseconds() - start
 	if winner == Reverse(root.Color) {
 		root.Wins++
+	} else if winner == BOTH {
+		// draw
+		root.Wins += 0.5
 	}
 	root.Visits++
 	root.Mean = root.Wins / root.Visits
@@ -257,9 +258,22 @@ func (root *Node) step(t Tracker) {
 
 // add all legal children to node
 func (node *Node) expand(t Tracker) {
+	var inferior []byte
+	if hex, ok := t.(*HexTracker); ok && node.config.Inferior {
+		inferior = hex.Inferior(Reverse(node.Color))
+	}
+	node.expandExcept(t, inferior)
+	if node.Child == nil && inferior != nil {
+		// only inferior cells are left, the winner no longer depends on the move
+		node.expandExcept(t, nil)
+	}
+}
+
+// add a child for every legal move, except on vertices marked in inferior
+func (node *Node) expandExcept(t Tracker, inferior []byte) {
 	color := Reverse(node.Color)
 	for i := -1; i < t.Sqsize(); i++ {
-		if t.Legal(color, i) {
+		if t.Legal(color, i) && (inferior == nil || i == -1 || inferior[i] == 0) {
 			child := NewNode(node, color, i)
 			if node.Child == nil {
 				node.Child = child
@@ -288,15 +302,22 @@ func (node *Node) Next(root *Node, t Tracker) *Node {
 	if node.Child == nil {
 		node.expand(t)
 		if node.Child == nil {
+			// no legal move: end the game by passing unless it is already over
 			cp := t.Copy()
-			cp.Play(node.Color, -1)
-			cp.Play(Reverse(node.Color), -1)
-			if node.Color == cp.Winner() {
+			if cp.Winner() == EMPTY {
+				cp.Play(node.Color, -1)
+				cp.Play(Reverse(node.Color), -1)
+			}
+			node.Visits = math.Inf(1)
+			switch cp.Winner() {
+			case node.Color:
 				node.Wins = math.Inf(1)
-			} else {
+			case BOTH:
+				// a draw is half a win per visit, as in update
+				node.Wins = node.Visits / 2
+			default:
 				node.Wins = 0
 			}
-			node.Visits = math.Inf(1)
 		}
 	}
 	var best *Node
@@ -331,6 +352,8 @@ func (node *Node) Best() *Node {
 func (node *Node) update(t Tracker) {
 	if t.Winner() == node.Color {
 		node.Wins++
+	} else if t.Winner() == BOTH {
+		node.Wins += 0.5
 	}
 	node.Visits++
 	node.recalc()
@@ -489,13 +512,52 @@ func (node *Node) seed(t Tracker, path []int) bool {
 	return false
 }
 
-func (root *Node) merge(node *Node) {
-	for child1, child2 := root.Child, node.Child; child1 != nil && child2 != nil; child1, child2 = child1.Sibling, child2.Sibling {
-		child1.Wins += child2.Wins
-		child1.Visits += child2.Visits
+// first-level statistics of a search, used to combine searches of the same position
+type RootStats struct {
+	Wins, Visits float64
+	Children     []ChildStats
+}
+
+type ChildStats struct {
+	Vertex       int
+	Wins, Visits float64
+}
+
+func (root *Node) Stats() *RootStats {
+	stats := &RootStats{Wins: root.Wins, Visits: root.Visits}
+	for child := root.Child; child != nil; child = child.Sibling {
+		stats.Children = append(stats.Children, ChildStats{child.Vertex, child.Wins, child.Visits})
+	}
+	return stats
+}
+
+// add the statistics of another search to root, matching children by vertex
+// children root doesn't have yet are added
+func (root *Node) merge(stats *RootStats) {
+	for _, stat := range stats.Children {
+		var child *Node
+		for child = root.Child; child != nil; child = child.Sibling {
+			if child.Vertex == stat.Vertex {
+				break
+			}
+		}
+		if child == nil {
+			child = NewNode(root, Reverse(root.Color), stat.Vertex)
+			if root.Child == nil {
+				root.Child = child
+			} else {
+				root.Last.Sibling = child
+			}
+			root.Last = child
+		}
+		child.Wins += stat.Wins
+		child.Visits += stat.Visits
+	}
+	root.Wins += stats.Wins
+	root.Visits += stats.Visits
+	for child := root.Child; child != nil; child = child.Sibling {
+		child.recalc()
 	}
-	root.Wins += node.Wins
-	root.Visits += node.Visits
 }
 
 func (node *Node) maxdepth() int {
@@ -553,54 +615,6 @@ func (node *Node) Play(color byte, vertex int, t Tracker) *Node {
 	return nil
 }
 
-func (root *Node) SaveBook() {
-	var filename string
-	if root.config.Prefix != "" {
-		filename = fmt.Sprintf(root.config.Prefix + ".book.gob")
-	} else {
-		filename = fmt.Sprintf("book.gob")
-	}
-	f, err := os.Create(filename)
-	if err != nil {
-		panic(err)
-	}
-	defer func() { f.Close() }()
-	e := gob.NewEncoder(f)
-	err = e.Encode(root)
-	if err != nil {
-		panic(err)
-	}
-}
-
-func (node *Node) fix_refs(config *Config) {
-	node.config = config
-	for child := node.Child; child != nil; child = child.Sibling {
-		child.parent = node
-		child.fix_refs(config)
-	}
-	if node.parent != nil {
-		node.recalc()
-	}
-}
-
-func LoadBook(config *Config) {
-	t := NewTracker(config)
-	config.book = NewRoot(BLACK, t, config)
-	if config.Bfile != "" {
-		f, err := os.Open(config.Bfile)
-		if err != nil {
-			panic(err)
-		}
-		defer func() { f.Close() }()
-		d := gob.NewDecoder(f)
-		err = d.Decode(config.book)
-		if err != nil {
-			panic(err)
-		}
-		config.book.fix_refs(config)
-	}
-}
-
 type Children struct {
 	vector.Vector
 }
@@ -632,8 +646,3 @@ func (node *Node) String(depth, maxdepth int, t Tracker) (s string) {
 	}
 	return
 }
-
-type Eval struct {
-	Moves        *vector.IntVector
-	Wins, Visits float64
-}
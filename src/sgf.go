@@ -1,9 +1,11 @@
 package main
 
 import (
-//"os"
-//"bufio"
-//"fmt"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -36,39 +38,70 @@ func (p *Property) String() string {
 	panic("property not supported")
 }
 
-func Load(filename string) (Tracker, byte) {
-	/*
-		file, _ := os.Open(filename)
-		defer func() { file.Close() }()
-		reader := bufio.NewReader(file)
-		// consume opening '('
-		reader.ReadString('(')
-		// consume opening ';'
-		reader.ReadString(';')
-		// throw out first node
-		reader.ReadString(';')
-		t := NewTracker(size)
-		more := true
-		var color byte
-		for more {
-			var s string
-			// pull out move nodes until no more left
-			s, _ = reader.ReadString(';')
-			if s == "" {
-				s, _ = reader.ReadString(')')
-				more = false
+/*
+	Load the main line of the first game in an SGF file, GM selects Go (1), Hex (11),
+	Gomoku (4) or Othello (2) and SZ and KM override config, SZ[w:h]
+	gives a rectangular board
+	Havannah and Y have no GM number, they are selected by config when GM is missing
+	returns the position and the color to move
+*/
+func Load(filename string, config *Config) (Tracker, byte) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		panic(err)
+	}
+	trees, err := ParseSGF(string(data))
+	if err != nil {
+		panic(err)
+	}
+	if len(trees) == 0 {
+		panic("no game in " + filename)
+	}
+	nodes := trees[0].MainLine()
+	root := nodes[0]
+	if gm, ok := root["GM"]; ok {
+		config.Go, config.Hex, config.Gomoku, config.Othello = gm[0] == "1", gm[0] == "11", gm[0] == "4", gm[0] == "2"
+		config.Havannah, config.Y = false, false
+		if !(config.Go || config.Hex || config.Gomoku || config.Othello) {
+			panic("unsupported game GM[" + gm[0] + "]")
+		}
+	}
+	if sz, ok := root["SZ"]; ok {
+		width, height, err := SGFSize(sz[0])
+		if err != nil {
+			panic(err)
+		}
+		config.Size, config.Width, config.Height = width, 0, 0
+		if width != height {
+			if !RectangularGame(config) {
+				panic(GameName(config) + " is only played on square boards")
 			}
-			if len(s) == 6 || len(s) == 7 {
-				color = Atoc(string(s[0]))
-				row := s[2] - 97
-				col := s[3] - 97
-				vertex := int(col*uint8(size) + row)
+			config.Width, config.Height = width, height
+		}
+	}
+	if km, ok := root["KM"]; ok {
+		if config.Komi, err = strconv.Atof64(km[0]); err != nil {
+			panic(err)
+		}
+	}
+	t := NewTracker(config)
+	color := WHITE
+	for _, node := range nodes {
+		for _, prop := range []string{"AB", "AW", "B", "W"} {
+			for _, value := range node[prop] {
+				vertex, err := SGFVertex(value, t.Width(), t.Height())
+				if err != nil {
+					panic(err)
+				}
+				color = Atoc(prop[len(prop)-1:])
 				t.Play(color, vertex)
 			}
 		}
-		return t, Reverse(color)
-	*/
-	return nil, EMPTY
+	}
+	if pl, ok := root["PL"]; ok && len(nodes) == 1 {
+		return t, Atoc(pl[0])
+	}
+	return t, Reverse(color)
 }
 
 func SGFMove(color byte, vertex int, Size int) (s string) {
@@ -100,3 +133,193 @@ func SGF(t Tracker) string {
 	*/
 	return ""
 }
+
+// a node of an SGF game tree, mapping property identifiers to their values
+type SGFNode map[string][]string
+
+type SGFTree struct {
+	Nodes      []SGFNode
+	Variations []*SGFTree
+}
+
+// nodes of the tree, following the first variation at every branch
+func (tree *SGFTree) MainLine() []SGFNode {
+	nodes := tree.Nodes
+	for len(tree.Variations) > 0 {
+		tree = tree.Variations[0]
+		nodes = append(nodes, tree.Nodes...)
+	}
+	return nodes
+}
+
+type sgfParser struct {
+	data string
+	pos  int
+}
+
+// parse an SGF collection, returning the game trees parsed before any error
+func ParseSGF(data string) ([]*SGFTree, os.Error) {
+	p := &sgfParser{data: data}
+	var trees []*SGFTree
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return trees, nil
+		}
+		tree, err := p.tree()
+		if err != nil {
+			return trees, err
+		}
+		trees = append(trees, tree)
+	}
+	return trees, nil
+}
+
+func (p *sgfParser) error(msg string) os.Error {
+	return os.NewError(fmt.Sprintf("sgf: %s at offset %d", msg, p.pos))
+}
+
+func (p *sgfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *sgfParser) tree() (*SGFTree, os.Error) {
+	if p.data[p.pos] != '(' {
+		return nil, p.error("expected (")
+	}
+	p.pos++
+	tree := new(SGFTree)
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, p.error("unexpected end of file")
+		}
+		switch p.data[p.pos] {
+		case ';':
+			p.pos++
+			node, err := p.node()
+			if err != nil {
+				return nil, err
+			}
+			tree.Nodes = append(tree.Nodes, node)
+		case '(':
+			variation, err := p.tree()
+			if err != nil {
+				return nil, err
+			}
+			tree.Variations = append(tree.Variations, variation)
+		case ')':
+			p.pos++
+			return tree, nil
+		default:
+			return nil, p.error(fmt.Sprintf("unexpected %q", p.data[p.pos]))
+		}
+	}
+	return tree, nil
+}
+
+func (p *sgfParser) node() (SGFNode, os.Error) {
+	node := make(SGFNode)
+	for {
+		p.skipSpace()
+		start := p.pos
+		// FF[3] allows lower case letters in identifiers, they are dropped
+		ident := ""
+		for ; p.pos < len(p.data); p.pos++ {
+			c := p.data[p.pos]
+			if c >= 'A' && c <= 'Z' {
+				ident += string(c)
+			} else if c < 'a' || c > 'z' {
+				break
+			}
+		}
+		if p.pos == start {
+			return node, nil
+		}
+		p.skipSpace()
+		if p.pos >= len(p.data) || p.data[p.pos] != '[' {
+			return nil, p.error("property " + ident + " has no value")
+		}
+		for p.pos < len(p.data) && p.data[p.pos] == '[' {
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			node[ident] = append(node[ident], value)
+			p.skipSpace()
+		}
+	}
+	return node, nil
+}
+
+func (p *sgfParser) value() (string, os.Error) {
+	// skip '['
+	p.pos++
+	value := make([]byte, 0, 16)
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '\\':
+			if p.pos < len(p.data) {
+				value = append(value, p.data[p.pos])
+				p.pos++
+			}
+		case ']':
+			return string(value), nil
+		default:
+			value = append(value, c)
+		}
+	}
+	return "", p.error("unterminated value")
+}
+
+// width and height of an SGF SZ value, either a size or width:height
+func SGFSize(s string) (int, int, os.Error) {
+	if i := strings.Index(s, ":"); i != -1 {
+		width, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, 0, err
+		}
+		height, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return 0, 0, err
+		}
+		return width, height, nil
+	}
+	size, err := strconv.Atoi(s)
+	return size, size, err
+}
+
+// vertex of an SGF point, either two letters (column, row) or a letter and a row number as used by Hex
+// an empty point, or tt on boards up to 19, is a pass
+func SGFVertex(s string, width, height int) (int, os.Error) {
+	if s == "" || (s == "tt" && width <= 19 && height <= 19) {
+		return -1, nil
+	}
+	if len(s) < 2 {
+		return -1, os.NewError("sgf: bad point " + s)
+	}
+	col := int(s[0]) - 'a'
+	row := int(s[1]) - 'a'
+	if s[1] >= '0' && s[1] <= '9' {
+		n, err := strconv.Atoi(s[1:])
+		if err != nil {
+			return -1, os.NewError("sgf: bad point " + s)
+		}
+		row = n - 1
+	} else if len(s) != 2 {
+		return -1, os.NewError("sgf: bad point " + s)
+	}
+	if col < 0 || col >= width || row < 0 || row >= height {
+		return -1, os.NewError("sgf: point " + s + " is off the board")
+	}
+	return row*width + col, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"json"
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+/*
+	Minimal TCP transport: a broker process owns a MemTransport and clients
+	send it one JSON frame per operation over a connection
+	subscriptions and pops each use their own connection so they can block
+*/
+type tcpFrame struct {
+	Op      string
+	Channel string
+	Data    []byte
+	Timeout int64
+}
+
+type tcpConn struct {
+	lock sync.Mutex
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+}
+
+func dialTCP(addr string) (*tcpConn, os.Error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpConn{conn: conn, enc: json.NewEncoder(conn), dec: json.NewDecoder(conn)}, nil
+}
+
+func (c *tcpConn) send(frame *tcpFrame) os.Error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.enc.Encode(frame)
+}
+
+type TCPTransport struct {
+	addr string
+	lock sync.Mutex
+	conn *tcpConn
+	pop  *tcpConn
+	// the connection of each subscription
+	subs map[<-chan []byte]*tcpConn
+}
+
+func NewTCPTransport(addr string) *TCPTransport {
+	if addr == "" {
+		addr = "127.0.0.1:6380"
+	}
+	return &TCPTransport{addr: addr, subs: make(map[<-chan []byte]*tcpConn)}
+}
+
+// lazily connect, so a worker can start before the broker
+func (t *TCPTransport) connect(c **tcpConn) (*tcpConn, os.Error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if *c == nil {
+		conn, err := dialTCP(t.addr)
+		if err != nil {
+			return nil, err
+		}
+		*c = conn
+	}
+	return *c, nil
+}
+
+// drop a broken connection so the next call reconnects
+func (t *TCPTransport) reset(c **tcpConn) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if *c != nil {
+		(*c).conn.Close()
+		*c = nil
+	}
+}
+
+func (t *TCPTransport) send(frame *tcpFrame) os.Error {
+	conn, err := t.connect(&t.conn)
+	if err != nil {
+		return err
+	}
+	if err = conn.send(frame); err != nil {
+		t.reset(&t.conn)
+	}
+	return err
+}
+
+func (t *TCPTransport) Publish(channel string, msg []byte) os.Error {
+	return t.send(&tcpFrame{Op: "pub", Channel: channel, Data: msg})
+}
+
+func (t *TCPTransport) Push(queue string, msg []byte) os.Error {
+	return t.send(&tcpFrame{Op: "push", Channel: queue, Data: msg})
+}
+
+func (t *TCPTransport) Subscribe(channel string) (<-chan []byte, os.Error) {
+	conn, err := dialTCP(t.addr)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.send(&tcpFrame{Op: "sub", Channel: channel}); err != nil {
+		return nil, err
+	}
+	out := make(chan []byte, 1024)
+	go func() {
+		defer conn.conn.Close()
+		for {
+			var frame tcpFrame
+			if err := conn.dec.Decode(&frame); err != nil {
+				log.Println("subscription to", channel, "closed:", err)
+				close(out)
+				return
+			}
+			out <- frame.Data
+		}
+	}()
+	t.lock.Lock()
+	t.subs[out] = conn
+	t.lock.Unlock()
+	return out, nil
+}
+
+// closing the connection ends the subscription on both ends
+func (t *TCPTransport) Unsubscribe(channel string, messages <-chan []byte) os.Error {
+	t.lock.Lock()
+	conn, ok := t.subs[messages]
+	t.subs[messages] = nil, false
+	t.lock.Unlock()
+	if !ok {
+		return os.NewError("not subscribed to " + channel)
+	}
+	return conn.conn.Close()
+}
+
+func (t *TCPTransport) Pop(queue string, timeout int64) ([]byte, os.Error) {
+	conn, err := t.connect(&t.pop)
+	if err != nil {
+		return nil, err
+	}
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	var frame tcpFrame
+	if err = conn.enc.Encode(&tcpFrame{Op: "pop", Channel: queue, Timeout: timeout}); err == nil {
+		err = conn.dec.Decode(&frame)
+	}
+	if err != nil {
+		t.reset(&t.pop)
+		return nil, err
+	}
+	return frame.Data, nil
+}
+
+// accept transport clients on l until it is closed
+func ServeBroker(l net.Listener) {
+	m := NewMemTransport()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Println("broker stopped:", err)
+			return
+		}
+		go serveBrokerConn(m, &tcpConn{conn: conn, enc: json.NewEncoder(conn), dec: json.NewDecoder(conn)})
+	}
+}
+
+func serveBrokerConn(m *MemTransport, c *tcpConn) {
+	defer c.conn.Close()
+	for {
+		var frame tcpFrame
+		if err := c.dec.Decode(&frame); err != nil {
+			return
+		}
+		switch frame.Op {
+		case "pub":
+			m.Publish(frame.Channel, frame.Data)
+		case "push":
+			m.Push(frame.Channel, frame.Data)
+		case "pop":
+			msg, _ := m.Pop(frame.Channel, frame.Timeout)
+			if err := c.send(&tcpFrame{Op: "msg", Channel: frame.Channel, Data: msg}); err != nil {
+				// the client went away, don't lose the job
+				if msg != nil {
+					m.Push(frame.Channel, msg)
+				}
+				return
+			}
+		case "sub":
+			s := m.subscribe(frame.Channel)
+			go func(channel string) {
+				for msg := range s {
+					if err := c.send(&tcpFrame{Op: "msg", Channel: channel, Data: msg}); err != nil {
+						m.Unsubscribe(channel, s)
+						return
+					}
+				}
+			}(frame.Channel)
+		}
+	}
+}
+
+func Broker(addr string) {
+	if addr == "" {
+		addr = "127.0.0.1:6380"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	log.Println("broker listening on", l.Addr())
+	ServeBroker(l)
+}
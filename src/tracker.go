@@ -2,6 +2,7 @@ package main
 
 import "container/vector"
 import "rand"
+import "sync"
 
 const (
 	UP          = 0
@@ -30,7 +31,8 @@ type Tracker interface {
 	Winner() byte
 	SetKomi(Komi float64)
 	GetKomi() float64
-	Boardsize() int
+	Width() int
+	Height() int
 	Sqsize() int
 	Board() []byte
 	Territory(color byte) []float64
@@ -51,10 +53,68 @@ func NewTracker(config *Config) Tracker {
 		} else {
 			return NewHexTracker(config)
 		}
+	} else if config.Gomoku {
+		return NewGomokuTracker(config)
+	} else if config.Havannah {
+		return NewHavannahTracker(config)
+	} else if config.Y {
+		return NewYTracker(config)
+	} else if config.Othello {
+		return NewOthelloTracker(config)
 	}
 	return nil
 }
 
+// name of the game config plays, as used in book and pattern files
+func GameName(config *Config) string {
+	if config.Go {
+		return "go"
+	} else if config.Hex {
+		return "hex"
+	} else if config.Gomoku {
+		return "gomoku"
+	} else if config.Havannah {
+		return "havannah"
+	} else if config.Y {
+		return "y"
+	} else if config.Othello {
+		return "othello"
+	}
+	return ""
+}
+
+// whether the game can be played on a board that is not square
+func RectangularGame(config *Config) bool {
+	return config.Go || config.Hex || config.Gomoku
+}
+
+// key of a width x height board in the tables of board geometry
+func board_key(width, height int) int {
+	return width<<8 | height
+}
+
+// largest board side, the columns are the letters A to Z without I
+const MAX_SIZE = 25
+
+// geometry tables are built the first time a size is used, possibly by concurrent cluster jobs
+var geometry_lock sync.Mutex
+
+// SGF GM property of the game config plays, 0 for Havannah and Y which have none
+// (13 is Neutron)
+func SGFGame(config *Config) int {
+	switch GameName(config) {
+	case "y", "havannah":
+		return 0
+	case "hex":
+		return 11
+	case "gomoku":
+		return 4
+	case "othello":
+		return 2
+	}
+	return 1
+}
+
 // standard union-find Find op, also does path compression
 func find(i int, parent []int) int {
 	if i == parent[i] {
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+	Message passing used by the cluster
+	channels are broadcast: every subscriber gets every message published after it subscribed
+	queues are work lists: each message pushed is popped by exactly one reader
+*/
+type Transport interface {
+	Publish(channel string, msg []byte) os.Error
+	Subscribe(channel string) (<-chan []byte, os.Error)
+	// stop a subscription, its channel is closed once no more messages will arrive
+	Unsubscribe(channel string, messages <-chan []byte) os.Error
+	Push(queue string, msg []byte) os.Error
+	// wait up to timeout nanoseconds for a message, returns nil if there was none
+	Pop(queue string, timeout int64) ([]byte, os.Error)
+}
+
+// shared by everything in the process using the memory transport
+var memTransport *MemTransport
+
+func NewTransport(config *Config) Transport {
+	switch config.Transport {
+	case "memory":
+		if memTransport == nil {
+			memTransport = NewMemTransport()
+		}
+		return memTransport
+	case "tcp":
+		return NewTCPTransport(config.ClusterAddr)
+	case "redis":
+		return NewRedisTransport(config.ClusterAddr)
+	}
+	panic("unknown transport " + config.Transport)
+}
+
+// in-process transport, for running the cluster and its tests on one machine
+type MemTransport struct {
+	lock        sync.Mutex
+	subscribers map[string][]chan []byte
+	queues      map[string]chan []byte
+}
+
+func NewMemTransport() *MemTransport {
+	m := new(MemTransport)
+	m.subscribers = make(map[string][]chan []byte)
+	m.queues = make(map[string]chan []byte)
+	return m
+}
+
+// a subscriber whose buffer is full misses the message, so one that stops reading can't stall every publisher
+func (m *MemTransport) Publish(channel string, msg []byte) os.Error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, s := range m.subscribers[channel] {
+		select {
+		case s <- msg:
+		default:
+			log.Println("dropped a message on", channel, "for a subscriber that is not reading")
+		}
+	}
+	return nil
+}
+
+func (m *MemTransport) Subscribe(channel string) (<-chan []byte, os.Error) {
+	return m.subscribe(channel), nil
+}
+
+func (m *MemTransport) subscribe(channel string) chan []byte {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s := make(chan []byte, 1024)
+	m.subscribers[channel] = append(m.subscribers[channel], s)
+	return s
+}
+
+func (m *MemTransport) Unsubscribe(channel string, messages <-chan []byte) os.Error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	remaining := make([]chan []byte, 0, len(m.subscribers[channel]))
+	for _, s := range m.subscribers[channel] {
+		if s == messages {
+			close(s)
+		} else {
+			remaining = append(remaining, s)
+		}
+	}
+	m.subscribers[channel] = remaining
+	return nil
+}
+
+func (m *MemTransport) queue(name string) chan []byte {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	q, exists := m.queues[name]
+	if !exists {
+		q = make(chan []byte, 4096)
+		m.queues[name] = q
+	}
+	return q
+}
+
+func (m *MemTransport) Push(queue string, msg []byte) os.Error {
+	m.queue(queue) <- msg
+	return nil
+}
+
+func (m *MemTransport) Pop(queue string, timeout int64) ([]byte, os.Error) {
+	select {
+	case msg := <-m.queue(queue):
+		return msg, nil
+	case <-time.After(timeout):
+	}
+	return nil, nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"json"
+	"log"
+	"math"
+	"os"
+	"rand"
+	"strings"
+	"time"
+)
+
+// a numeric Config field that can be tuned
+// values are tuned in the normalized range [0, 1] and mapped onto [Min, Max]
+type TuneParam struct {
+	Field    string
+	Min, Max float64
+	get      func(config *Config) float64
+	set      func(config *Config, value float64)
+}
+
+var tune_params = map[string]*TuneParam{
+	"c": &TuneParam{"Explore", 0, 2,
+		func(config *Config) float64 { return config.Explore },
+		func(config *Config, value float64) { config.Explore = value }},
+	"k": &TuneParam{"RAVE", 1, 10000,
+		func(config *Config) float64 { return config.RAVE },
+		func(config *Config, value float64) { config.RAVE = value }},
+	"e": &TuneParam{"ExpandAfter", 0, 500,
+		func(config *Config) float64 { return config.ExpandAfter },
+		func(config *Config, value float64) { config.ExpandAfter = math.Floor(value + 0.5) }},
+}
+
+// SPSA gain sequence constants, see Spall (1998)
+const (
+	SPSA_A     = 0.01
+	SPSA_C     = 0.1
+	SPSA_ALPHA = 0.602
+	SPSA_GAMMA = 0.101
+)
+
+/*
+	Simultaneous perturbation stochastic approximation over a subset of Config
+	each iteration perturbs all parameters at once by +/- c_k, plays theta+ against theta-
+	and moves theta along the estimated gradient
+*/
+type Tuner struct {
+	Params    []string
+	Theta     []float64
+	Iteration uint
+	Results   []float64
+	config    *Config
+}
+
+func NewTuner(config *Config) *Tuner {
+	tuner := new(Tuner)
+	tuner.config = config
+	for _, name := range strings.Split(config.TuneParams, ",") {
+		name = strings.TrimSpace(name)
+		param, exists := tune_params[name]
+		if !exists {
+			panic("unknown tuning parameter " + name)
+		}
+		tuner.Params = append(tuner.Params, name)
+		tuner.Theta = append(tuner.Theta, (param.get(config)-param.Min)/(param.Max-param.Min))
+	}
+	return tuner
+}
+
+// return a copy of the tuner's config with theta applied
+func (tuner *Tuner) apply(theta []float64) *Config {
+	config := new(Config)
+	*config = *tuner.config
+	for i, name := range tuner.Params {
+		param := tune_params[name]
+		param.set(config, param.Min+theta[i]*(param.Max-param.Min))
+	}
+	return config
+}
+
+func (tuner *Tuner) step() {
+	k := float64(tuner.Iteration)
+	a := SPSA_A / math.Pow(k+1+0.1*float64(tuner.config.TuneIterations), SPSA_ALPHA)
+	c := SPSA_C / math.Pow(k+1, SPSA_GAMMA)
+	delta := make([]float64, len(tuner.Theta))
+	plus := make([]float64, len(tuner.Theta))
+	minus := make([]float64, len(tuner.Theta))
+	for i := range tuner.Theta {
+		delta[i] = 1
+		if rand.Float64() < 0.5 {
+			delta[i] = -1
+		}
+		plus[i] = clamp(tuner.Theta[i]+c*delta[i], 0, 1)
+		minus[i] = clamp(tuner.Theta[i]-c*delta[i], 0, 1)
+	}
+	pc, mc := tuner.apply(plus), tuner.apply(minus)
+	// score is from the point of view of theta+, 1 for a win, 0.5 for an unfinished game
+	score := 0.0
+	for game := uint(0); game < tuner.config.TuneGames; game++ {
+		var winner byte
+		if game%2 == 0 {
+			winner = playMatch(pc, mc)
+		} else {
+			winner = Reverse(playMatch(mc, pc))
+		}
+		switch winner {
+		case BLACK:
+			score++
+		case EMPTY:
+			score += 0.5
+		}
+	}
+	result := 2*score/float64(tuner.config.TuneGames) - 1
+	tuner.update(a, result, plus, minus)
+	tuner.Results = append(tuner.Results, result)
+	tuner.Iteration++
+}
+
+// move theta by gain a along the gradient given by result, the score of plus against minus
+// near the bounds the points are clamped, so the gradient is over the distance they actually are apart
+func (tuner *Tuner) update(a, result float64, plus, minus []float64) {
+	for i := range tuner.Theta {
+		if plus[i] != minus[i] {
+			tuner.Theta[i] = clamp(tuner.Theta[i]+a*result/(plus[i]-minus[i]), 0, 1)
+		}
+	}
+}
+
+func clamp(x, min, max float64) float64 {
+	return math.Fmax(min, math.Fmin(max, x))
+}
+
+// play a game between two configurations, returning the winner, or EMPTY if the game is unfinished
+func playMatch(black *Config, white *Config) byte {
+	t := NewTracker(black)
+	color := BLACK
+	for move := 0; t.Winner() == EMPTY && move < 2*t.Sqsize(); move++ {
+		config := black
+		if color == WHITE {
+			config = white
+		}
+		root := NewRoot(color, t, config)
+		genmove(root, t)
+		t.Play(color, root.Best().Vertex)
+		color = Reverse(color)
+	}
+	return t.Winner()
+}
+
+func (tuner *Tuner) filename(suffix string) string {
+	if tuner.config.Prefix != "" {
+		return tuner.config.Prefix + "." + suffix
+	}
+	return suffix
+}
+
+func (tuner *Tuner) Save() {
+	f, err := os.Create(tuner.filename("tune.json"))
+	if err != nil {
+		panic(err)
+	}
+	defer func() { f.Close() }()
+	if err = json.NewEncoder(f).Encode(tuner); err != nil {
+		panic(err)
+	}
+}
+
+func (tuner *Tuner) Load(filename string) {
+	f, err := os.Open(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer func() { f.Close() }()
+	if err = json.NewDecoder(f).Decode(tuner); err != nil {
+		panic(err)
+	}
+}
+
+// write the tuned fields as a JSON config suitable for -cfile
+func (tuner *Tuner) SaveConfig() {
+	config := tuner.apply(tuner.Theta)
+	fields := make(map[string]float64)
+	for _, name := range tuner.Params {
+		param := tune_params[name]
+		fields[param.Field] = param.get(config)
+	}
+	bytes, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	f, err := os.Create(tuner.filename("tuned.json"))
+	if err != nil {
+		panic(err)
+	}
+	defer func() { f.Close() }()
+	f.Write(bytes)
+}
+
+func (tuner *Tuner) String() (s string) {
+	config := tuner.apply(tuner.Theta)
+	for _, name := range tuner.Params {
+		param := tune_params[name]
+		s += fmt.Sprintf("%s=%.4f ", name, param.get(config))
+	}
+	return
+}
+
+func Tune(config *Config) {
+	tuner := NewTuner(config)
+	if config.Tfile != "" {
+		tuner.Load(config.Tfile)
+	}
+	for tuner.Iteration < config.TuneIterations {
+		start := time.Nanoseconds()
+		tuner.step()
+		tuner.Save()
+		tuner.SaveConfig()
+		log.Printf("iteration %d/%d, result: %.2f, %s, took %d seconds",
+			tuner.Iteration, config.TuneIterations, tuner.Results[len(tuner.Results)-1],
+			tuner.String(), (time.Nanoseconds()-start)/1e9)
+	}
+}
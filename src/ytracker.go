@@ -0,0 +1,422 @@
+package main
+
+import (
+	"container/vector"
+	"fmt"
+	"log"
+	"rand"
+	"strconv"
+	"strings"
+)
+
+/*
+	The Game of Y on a triangular board with config.Size cells per side
+	the board is stored in the top left half of a Size x Size Hex board, cells with
+	row+col >= Size are ILLEGAL
+	as in HexTracker, the sides are extra nodes in the union-find structure, one set
+	of three for each color so black and white groups never meet through a side
+	a group touching all three sides wins, corners touch two sides
+*/
+type YTracker struct {
+	boardsize int
+	sqsize    int
+	parent    []int
+	rank      []int
+	board     []byte
+	weights   *WeightTree
+	winner    byte
+	played    []byte
+	adj       []int
+	neighbors [][]int
+	moves     *vector.IntVector
+	config    *Config
+}
+
+// sides of the triangle, added to sqsize in adj
+const (
+	Y_SIDE_TOP   = 0
+	Y_SIDE_LEFT  = 1
+	Y_SIDE_RIGHT = 2
+)
+
+func NewYTracker(config *Config) *YTracker {
+	t := new(YTracker)
+
+	t.boardsize = config.Size
+	t.sqsize = t.boardsize * t.boardsize
+	t.adj, t.neighbors = y_geometry(t.boardsize)
+	t.board = make([]byte, t.sqsize)
+	t.parent = make([]int, t.sqsize+6)
+	t.rank = make([]int, t.sqsize+6)
+	t.weights = NewWeightTree(t.sqsize)
+	// initialize union-find data structure
+	for i := 0; i < t.sqsize+6; i++ {
+		t.parent[i] = i
+		if i < t.sqsize {
+			t.rank[i] = 1
+		} else {
+			t.rank[i] = t.sqsize
+		}
+		if i < t.sqsize {
+			if i/t.boardsize+i%t.boardsize < t.boardsize {
+				t.weights.Set(BLACK, i, INIT_WEIGHT)
+				t.weights.Set(WHITE, i, INIT_WEIGHT)
+			} else {
+				t.board[i] = ILLEGAL
+			}
+		}
+	}
+
+	t.winner = EMPTY
+
+	t.played = make([]byte, t.sqsize)
+
+	t.moves = new(vector.IntVector)
+
+	t.config = config
+
+	return t
+}
+
+func (t *YTracker) Copy() Tracker {
+	cp := new(YTracker)
+
+	cp.boardsize = t.boardsize
+	cp.sqsize = t.sqsize
+	cp.adj = t.adj
+	cp.neighbors = t.neighbors
+	cp.board = make([]byte, cp.sqsize)
+	cp.parent = make([]int, cp.sqsize+6)
+	cp.rank = make([]int, cp.sqsize+6)
+	copy(cp.parent, t.parent)
+	copy(cp.rank, t.rank)
+	copy(cp.board, t.board)
+	cp.weights = t.weights.Copy()
+
+	cp.winner = t.winner
+
+	cp.played = make([]byte, cp.sqsize)
+
+	cp.moves = new(vector.IntVector)
+	*cp.moves = t.moves.Copy()
+
+	cp.config = t.config
+
+	return cp
+}
+
+// union-find node of side for color
+func (t *YTracker) side(color byte, side int) int {
+	return t.sqsize + 3*int(color-BLACK) + side
+}
+
+func (t *YTracker) Play(color byte, vertex int) {
+	if vertex != -1 {
+		if t.board[vertex] != EMPTY {
+			log.Println(t.String())
+			log.Println(Ctoa(color), t.Vtoa(vertex))
+			panic("play on non-empty vertex")
+		}
+		root := find(vertex, t.parent)
+		for i := 0; i < 6; i++ {
+			adj := t.adj[vertex*6+i]
+			if adj >= t.sqsize {
+				root = fastUnion(root, find(t.side(color, adj-t.sqsize), t.parent), t.parent, t.rank)
+			} else if adj != -1 && t.board[adj] == color {
+				root = fastUnion(root, find(adj, t.parent), t.parent, t.rank)
+			}
+		}
+		t.board[vertex] = color
+		top := find(t.side(color, Y_SIDE_TOP), t.parent)
+		if top == find(t.side(color, Y_SIDE_LEFT), t.parent) && top == find(t.side(color, Y_SIDE_RIGHT), t.parent) {
+			t.winner = color
+		}
+		// cannot play on occupied vertex
+		t.weights.Set(BLACK, vertex, 0)
+		t.weights.Set(WHITE, vertex, 0)
+		if t.config.PlayoutProbs && t.config.policy_weights != nil {
+			t.updateNeighborWeights(vertex)
+		}
+
+		if t.played[vertex] == EMPTY {
+			t.played[vertex] = color
+		}
+	}
+	t.moves.Push(vertex)
+}
+
+func (t *YTracker) updateNeighborWeights(vertex int) {
+	for i := range t.neighbors[vertex] {
+		neighbor := t.neighbors[vertex][i]
+		if neighbor != -1 && t.board[neighbor] == EMPTY {
+			t.updateWeights(BLACK, neighbor, neighbor)
+			t.updateWeights(WHITE, neighbor, neighbor)
+			t.updateWeights(BLACK, vertex, neighbor)
+			t.updateWeights(WHITE, vertex, neighbor)
+		}
+	}
+}
+
+func (t *YTracker) updateWeights(color byte, v1, v2 int) {
+	weight := t.get_pattern_weight(color, v1) * t.weights.Get(color, v2)
+	if weight == 0 {
+		weight = 1
+	}
+	t.weights.Set(color, v2, weight)
+}
+
+// Y has no preferred directions, so patterns use all the symmetries of the hexagon, as in Havannah
+func (t *YTracker) get_pattern_weight(color byte, vertex int) float64 {
+	hash := havannah_min_hash[hex_hash(color, t.board, t.neighbors[vertex])]
+	return t.config.policy_weights.Get(hash)
+}
+
+func (t *YTracker) suggestion(color byte, last int) int {
+	if last == -1 || !t.config.PlayoutSuggest {
+		return -1
+	}
+	if t.config.policy_weights == nil && !t.config.PlayoutSuggestUniform {
+		return -1
+	}
+	var weights [6]float64
+	weightSum := 0.0
+	for i := 0; i < 6; i++ {
+		n := t.neighbors[last][i]
+		if n != -1 && t.board[n] == EMPTY {
+			if t.config.PlayoutSuggestUniform {
+				weights[i] = 1
+			} else {
+				hash := havannah_min_hash[hex_hash(color, t.board, t.neighbors[n])]
+				hash |= LOCAL_PATTERN
+				weights[i] = t.config.policy_weights.Get(hash)
+			}
+			weightSum += weights[i]
+		}
+	}
+	if weightSum > 0 {
+		r := rand.Float64() * weightSum
+		for i := range weights {
+			if weights[i] > 0 {
+				r -= weights[i]
+				if r <= 0 {
+					return t.neighbors[last][i]
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// a full board always has a winner
+func (t *YTracker) Playout(color byte) {
+	vertex := -1
+	for t.winner == EMPTY {
+		vertex = t.suggestion(color, vertex)
+		if vertex == -1 {
+			vertex = t.weights.Rand(color)
+		}
+		if t.config.VeryVerbose {
+			log.Println(Ctoa(color) + t.Vtoa(vertex))
+		}
+		t.Play(color, vertex)
+		if t.config.VeryVerbose {
+			log.Println(t.String())
+		}
+		color = Reverse(color)
+	}
+	if t.config.VeryVerbose {
+		log.Println("FINAL: " + Ctoa(t.winner))
+	}
+}
+
+func (t *YTracker) WasPlayed(color byte, vertex int) bool {
+	return t.played[vertex] == color
+}
+
+func (t *YTracker) Legal(color byte, vertex int) bool {
+	return vertex != -1 && t.board[vertex] == EMPTY
+}
+
+func (t *YTracker) Score(Komi float64) (float64, float64) {
+	if t.winner == BLACK {
+		return 1, 0
+	} else if t.winner == WHITE {
+		return 0, 1
+	}
+	return 0, 0
+}
+
+func (t *YTracker) Winner() byte {
+	return t.winner
+}
+
+func (t *YTracker) SetKomi(Komi float64) {
+
+}
+
+func (t *YTracker) GetKomi() float64 {
+	return 0
+}
+
+func (t *YTracker) Width() int {
+	return t.boardsize
+}
+
+func (t *YTracker) Height() int {
+	return t.boardsize
+}
+
+func (t *YTracker) Sqsize() int {
+	return t.sqsize
+}
+
+func (t *YTracker) Board() []byte {
+	return t.board
+}
+
+func (t *YTracker) Territory(color byte) []float64 {
+	territory := make([]float64, t.sqsize)
+	for i := range t.board {
+		if t.board[i] == color {
+			territory[i] = 1
+		}
+	}
+	return territory
+}
+
+func (t *YTracker) Verify() {
+}
+
+func (t *YTracker) Adj(vertex int) []int {
+	return t.adj[vertex*6 : (vertex+1)*6]
+}
+
+func (t *YTracker) Moves() *vector.IntVector {
+	return t.moves
+}
+
+func (t *YTracker) Vtoa(v int) string {
+	if v == -1 {
+		return "PASS"
+	}
+	alpha, num := v%t.boardsize, v/t.boardsize
+	num++
+	alpha = alpha + 'A'
+	if alpha >= 'I' {
+		alpha++
+	}
+	return fmt.Sprintf("%s%d", string(alpha), num)
+}
+
+func (t *YTracker) Atov(s string) int {
+	if s == "PASS" || s == "pass" {
+		return -1
+	}
+	// pull apart into alpha and int pair
+	col := byte(strings.ToUpper(s)[0])
+	row, err := strconv.Atoi(s[1:len(s)])
+	row--
+	if col >= 'I' {
+		col--
+	}
+	if err != nil {
+		panic("Failed to convert string to vertex")
+	}
+	return row*t.boardsize + int(col-'A')
+}
+
+// row r is indented by r, as on a Hex board, which shows the triangle
+func (t *YTracker) String() (s string) {
+	s += "   "
+	for col := 0; col < t.boardsize; col++ {
+		alpha := col + 'A'
+		if alpha >= 'I' {
+			alpha++
+		}
+		s += string(alpha)
+		if col != t.boardsize-1 {
+			s += " "
+		}
+	}
+	for row := 0; row < t.boardsize; row++ {
+		s += "\n"
+		for i := 0; i < row; i++ {
+			s += " "
+		}
+		s += fmt.Sprintf("%2.d ", row+1)
+		for col := 0; col < t.boardsize-row; col++ {
+			s += Ctoa(t.board[row*t.boardsize+col])
+			if col != t.boardsize-row-1 {
+				s += " "
+			}
+		}
+	}
+	return
+}
+
+// built by y_geometry the first time a size is used
+var y_adj map[int][]int
+var y_neighbors map[int][][]int
+
+func init() {
+	y_adj = make(map[int][]int)
+	y_neighbors = make(map[int][][]int)
+}
+
+func y_geometry(boardsize int) ([]int, [][]int) {
+	geometry_lock.Lock()
+	defer geometry_lock.Unlock()
+	if _, ok := y_adj[boardsize]; !ok {
+		setup_y_adj(boardsize)
+	}
+	return y_adj[boardsize], y_neighbors[boardsize]
+}
+
+/*
+	Neighbors in the same order as hex_neighbors: up, up right, right, down,
+	down left, left
+	in y_adj, directions off the board hold sqsize plus the side they cross, and
+	directions onto ILLEGAL cells hold -1, in y_neighbors both hold -1
+*/
+func setup_y_adj(boardsize int) {
+	s := boardsize * boardsize
+	y_adj[boardsize] = make([]int, s*6)
+	y_neighbors[boardsize] = make([][]int, s)
+	dirs := [6][2]int{
+		[2]int{-1, 0},
+		[2]int{-1, 1},
+		[2]int{0, 1},
+		[2]int{1, 0},
+		[2]int{1, -1},
+		[2]int{0, -1},
+	}
+	for row := 0; row < boardsize; row++ {
+		for col := 0; col < boardsize; col++ {
+			v := row*boardsize + col
+			y_neighbors[boardsize][v] = make([]int, 7)
+			y_neighbors[boardsize][v][6] = v
+			for i := range dirs {
+				r, c := row+dirs[i][0], col+dirs[i][1]
+				n := -1
+				switch {
+				case row+col >= boardsize:
+					// ILLEGAL cells have no neighbors
+				case r < 0:
+					n = s + Y_SIDE_TOP
+				case c < 0:
+					n = s + Y_SIDE_LEFT
+				case r+c >= boardsize:
+					n = s + Y_SIDE_RIGHT
+				default:
+					n = r*boardsize + c
+				}
+				y_adj[boardsize][v*6+i] = n
+				if n < s {
+					y_neighbors[boardsize][v][i] = n
+				} else {
+					y_neighbors[boardsize][v][i] = -1
+				}
+			}
+		}
+	}
+}
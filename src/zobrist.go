@@ -4,67 +4,96 @@ import (
 	"rand"
 )
 
-// Zobrist hashing
-var emptyBoard map[int][]Hash
-var blackBoard map[int][]Hash
-var whiteBoard map[int][]Hash
+// Zobrist hashing, with one set of keys per board size
+type Zobrist struct {
+	empty, black, white []Hash
+	// 64 bit keys indexed by color then vertex, for books, which hold too many positions for 32 bits
+	book [3][]BookHash
+}
+
+// keyed by board_key, built by zobrist_keys the first time a size is used
+var zobrist_tables map[int]*Zobrist
 
 func init() {
-	emptyBoard = make(map[int][]Hash)
-	blackBoard = make(map[int][]Hash)
-	whiteBoard = make(map[int][]Hash)
-	for size := 2; size <= 19; size++ {
-		setupHash(size)
+	zobrist_tables = make(map[int]*Zobrist)
+}
+
+func setupHash(width, height int) *Zobrist {
+	// square boards keep the seeds they always had, so hashes saved in books stay valid
+	seed := int64(board_key(width, height))
+	if width == height {
+		seed = int64(width)
+	}
+	r := rand.New(rand.NewSource(seed))
+	z := new(Zobrist)
+	z.empty = make([]Hash, width*height)
+	z.black = make([]Hash, width*height)
+	z.white = make([]Hash, width*height)
+	for i := 0; i < width*height; i++ {
+		z.empty[i] = Hash(r.Uint32())
+		z.black[i] = Hash(r.Uint32())
+		z.white[i] = Hash(r.Uint32())
+	}
+	// a source of their own, so the 32 bit keys above don't change
+	r = rand.New(rand.NewSource(-seed))
+	for color := range z.book {
+		z.book[color] = make([]BookHash, width*height)
+		for i := range z.book[color] {
+			z.book[color][i] = BookHash(r.Uint32())<<32 | BookHash(r.Uint32())
+		}
 	}
+	return z
 }
 
-func setupHash(size int) {
-	emptyBoard[size] = make([]Hash, size*size)
-	blackBoard[size] = make([]Hash, size*size)
-	whiteBoard[size] = make([]Hash, size*size)
-	rand.Seed(int64(size))
-	for i := 0; i < size*size; i++ {
-		emptyBoard[size][i] = Hash(rand.Uint32())
-		blackBoard[size][i] = Hash(rand.Uint32())
-		whiteBoard[size][i] = Hash(rand.Uint32())
+// Zobrist keys of a width x height board
+func zobrist_keys(width, height int) *Zobrist {
+	geometry_lock.Lock()
+	defer geometry_lock.Unlock()
+	key := board_key(width, height)
+	z, ok := zobrist_tables[key]
+	if !ok {
+		z = setupHash(width, height)
+		zobrist_tables[key] = z
 	}
+	return z
 }
 
 type Hash uint32
 
-func NewHash(size int) (hash *Hash) {
+func NewHash(z *Zobrist) (hash *Hash) {
 	hash = new(Hash)
-	for i := 0; i < len(emptyBoard[size]); i++ {
-		*hash ^= emptyBoard[size][i]
+	for i := 0; i < len(z.empty); i++ {
+		*hash ^= z.empty[i]
 	}
 	return
 }
 
 func MakeHash(t Tracker) *Hash {
-	hash := NewHash(t.Boardsize())
+	z := zobrist_keys(t.Width(), t.Height())
+	hash := NewHash(z)
 	board := t.Board()
 	for i := 0; i < t.Sqsize(); i++ {
-		hash.Update(t.Boardsize(), EMPTY, board[i], i)
+		hash.Update(z, EMPTY, board[i], i)
 	}
 	return hash
 }
 
-func (hash *Hash) Update(size int, oldColor byte, newColor byte, vertex int) {
+func (hash *Hash) Update(z *Zobrist, oldColor byte, newColor byte, vertex int) {
 	switch oldColor {
 	case BLACK:
-		*hash ^= blackBoard[size][vertex]
+		*hash ^= z.black[vertex]
 	case WHITE:
-		*hash ^= whiteBoard[size][vertex]
+		*hash ^= z.white[vertex]
 	case EMPTY:
-		*hash ^= emptyBoard[size][vertex]
+		*hash ^= z.empty[vertex]
 	}
 	switch newColor {
 	case BLACK:
-		*hash ^= blackBoard[size][vertex]
+		*hash ^= z.black[vertex]
 	case WHITE:
-		*hash ^= whiteBoard[size][vertex]
+		*hash ^= z.white[vertex]
 	case EMPTY:
-		*hash ^= emptyBoard[size][vertex]
+		*hash ^= z.empty[vertex]
 	}
 }
 